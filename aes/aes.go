@@ -2,38 +2,54 @@ package aes
 
 import (
 	"encoding/binary"
+
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/RainbowDashy/cipher/trace"
 )
 
 func encryptBlock(w []uint32, dst, src []byte) {
+	encryptRounds(w, false, nil, dst, src)
+}
+
+func decrptyBlock(w []uint32, dst, src []byte) {
+	decryptRounds(w, false, nil, dst, src)
+}
+
+// encryptRounds runs len(w)/4-1 rounds. The last round keeps MixColumns if
+// finalMix is set. Every step is reported to tr unless it is nil.
+func encryptRounds(w []uint32, finalMix bool, tr trace.Tracer, dst, src []byte) {
 	s0 := binary.BigEndian.Uint32(src[0:4])
 	s1 := binary.BigEndian.Uint32(src[4:8])
 	s2 := binary.BigEndian.Uint32(src[8:12])
 	s3 := binary.BigEndian.Uint32(src[12:16])
 
+	traceState(tr, 0, "input", s0, s1, s2, s3)
+	traceState(tr, 0, "k_sch", w[0], w[1], w[2], w[3])
 	s0 ^= w[0]
 	s1 ^= w[1]
 	s2 ^= w[2]
 	s3 ^= w[3]
 
-	nr := 10
+	nr := len(w)/4 - 1
 	k := 4
-	for r := 1; r < nr; r++ {
+	for r := 1; r <= nr; r++ {
+		traceState(tr, r, "start", s0, s1, s2, s3)
 		s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+		traceState(tr, r, "s_box", s0, s1, s2, s3)
 		s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
-		s0, s1, s2, s3 = mixColumns(s0, s1, s2, s3)
+		traceState(tr, r, "s_row", s0, s1, s2, s3)
+		if r < nr || finalMix {
+			s0, s1, s2, s3 = mixColumns(s0, s1, s2, s3)
+			traceState(tr, r, "m_col", s0, s1, s2, s3)
+		}
+		traceState(tr, r, "k_sch", w[k+0], w[k+1], w[k+2], w[k+3])
 		s0 ^= w[k+0]
 		s1 ^= w[k+1]
 		s2 ^= w[k+2]
 		s3 ^= w[k+3]
 		k += 4
 	}
-
-	s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
-	s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
-	s0 ^= w[k+0]
-	s1 ^= w[k+1]
-	s2 ^= w[k+2]
-	s3 ^= w[k+3]
+	traceState(tr, nr, "output", s0, s1, s2, s3)
 
 	binary.BigEndian.PutUint32(dst[0:4], s0)
 	binary.BigEndian.PutUint32(dst[4:8], s1)
@@ -41,37 +57,42 @@ func encryptBlock(w []uint32, dst, src []byte) {
 	binary.BigEndian.PutUint32(dst[12:16], s3)
 }
 
-func decrptyBlock(w []uint32, dst, src []byte) {
+func decryptRounds(w []uint32, finalMix bool, tr trace.Tracer, dst, src []byte) {
 	s0 := binary.BigEndian.Uint32(src[0:4])
 	s1 := binary.BigEndian.Uint32(src[4:8])
 	s2 := binary.BigEndian.Uint32(src[8:12])
 	s3 := binary.BigEndian.Uint32(src[12:16])
 
-	nr := 10
+	nr := len(w)/4 - 1
 	k := 4 * nr
+	traceState(tr, 0, "iinput", s0, s1, s2, s3)
+	traceState(tr, 0, "ik_sch", w[k+0], w[k+1], w[k+2], w[k+3])
 	s0 ^= w[k+0]
 	s1 ^= w[k+1]
 	s2 ^= w[k+2]
 	s3 ^= w[k+3]
+	if finalMix {
+		s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
+	}
 
-	for r := 1; r < nr; r++ {
+	for r := 1; r <= nr; r++ {
+		traceState(tr, r, "istart", s0, s1, s2, s3)
 		s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+		traceState(tr, r, "is_row", s0, s1, s2, s3)
 		s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+		traceState(tr, r, "is_box", s0, s1, s2, s3)
 		k -= 4
+		traceState(tr, r, "ik_sch", w[k+0], w[k+1], w[k+2], w[k+3])
 		s0 ^= w[k+0]
 		s1 ^= w[k+1]
 		s2 ^= w[k+2]
 		s3 ^= w[k+3]
-		s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
+		if r < nr {
+			traceState(tr, r, "ik_add", s0, s1, s2, s3)
+			s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
+		}
 	}
-
-	s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
-	s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
-	k -= 4
-	s0 ^= w[k+0]
-	s1 ^= w[k+1]
-	s2 ^= w[k+2]
-	s3 ^= w[k+3]
+	traceState(tr, nr, "ioutput", s0, s1, s2, s3)
 
 	binary.BigEndian.PutUint32(dst[0:4], s0)
 	binary.BigEndian.PutUint32(dst[4:8], s1)
@@ -79,17 +100,50 @@ func decrptyBlock(w []uint32, dst, src []byte) {
 	binary.BigEndian.PutUint32(dst[12:16], s3)
 }
 
+// traceState reports the four columns to tr if it is set.
+func traceState(tr trace.Tracer, round int, step string, s0, s1, s2, s3 uint32) {
+	if tr != nil {
+		traceColumns(tr, round, step, s0, s1, s2, s3)
+	}
+}
+
+func traceColumns(tr trace.Tracer, round int, step string, s0, s1, s2, s3 uint32) {
+	var b [16]byte
+	binary.BigEndian.PutUint32(b[0:4], s0)
+	binary.BigEndian.PutUint32(b[4:8], s1)
+	binary.BigEndian.PutUint32(b[8:12], s2)
+	binary.BigEndian.PutUint32(b[12:16], s3)
+	tr.Trace(round, step, b[:])
+}
+
 func subw(t uint32) uint32 {
-	return uint32(sbox0[t>>24])<<24 | uint32(sbox0[t>>16&0xff])<<16 | uint32(sbox0[t>>8&0xff])<<8 | uint32(sbox0[t&0xff])
+	return uint32(gf256.AESSBox[t>>24])<<24 | uint32(gf256.AESSBox[t>>16&0xff])<<16 | uint32(gf256.AESSBox[t>>8&0xff])<<8 | uint32(gf256.AESSBox[t&0xff])
 }
 
 func rotw(t uint32) uint32 {
 	return t<<8 | t>>24
 }
 
+// rounds returns the number of rounds for a key of keyLen bytes, or 0 if the
+// length is not one of 16, 24 or 32.
+func rounds(keyLen int) int {
+	switch keyLen {
+	case 16, 24, 32:
+		return keyLen/4 + 6
+	}
+	return 0
+}
+
 func keyExpansion(key []byte, w []uint32) {
-	if len(key) != 16 {
-		panic("only support 128-bit key")
+	expandKey(key, w, subw)
+}
+
+// expandKey runs the key expansion with the given SubWord. It fills all of
+// w, so reduced-round and extended schedules of up to 15 round keys come out
+// of the same recurrence.
+func expandKey(key []byte, w []uint32, subw func(uint32) uint32) {
+	if rounds(len(key)) == 0 {
+		panic("only support 128, 192 and 256-bit keys")
 	}
 	i := 0
 	nk := len(key) / 4
@@ -99,7 +153,9 @@ func keyExpansion(key []byte, w []uint32) {
 	for ; i < len(w); i++ {
 		t := w[i-1]
 		if i%nk == 0 {
-			t = subw(rotw(t)) ^ rcon[i/nk]
+			t = subw(rotw(t)) ^ uint32(gf256.AESRcon[i/nk])<<24
+		} else if nk > 6 && i%nk == 4 {
+			t = subw(t)
 		}
 		w[i] = w[i-nk] ^ t
 	}
@@ -107,14 +163,14 @@ func keyExpansion(key []byte, w []uint32) {
 
 func subBytes(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 	f := func(t uint32) uint32 {
-		return uint32(sbox0[t>>24])<<24 | uint32(sbox0[t>>16&0xff])<<16 | uint32(sbox0[t>>8&0xff])<<8 | uint32(sbox0[t&0xff])
+		return uint32(gf256.AESSBox[t>>24])<<24 | uint32(gf256.AESSBox[t>>16&0xff])<<16 | uint32(gf256.AESSBox[t>>8&0xff])<<8 | uint32(gf256.AESSBox[t&0xff])
 	}
 	return f(s0), f(s1), f(s2), f(s3)
 }
 
 func invSubBytes(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 	f := func(t uint32) uint32 {
-		return uint32(sbox1[t>>24])<<24 | uint32(sbox1[t>>16&0xff])<<16 | uint32(sbox1[t>>8&0xff])<<8 | uint32(sbox1[t&0xff])
+		return uint32(gf256.AESInvSBox[t>>24])<<24 | uint32(gf256.AESInvSBox[t>>16&0xff])<<16 | uint32(gf256.AESInvSBox[t>>8&0xff])<<8 | uint32(gf256.AESInvSBox[t&0xff])
 	}
 	return f(s0), f(s1), f(s2), f(s3)
 }
@@ -136,25 +192,27 @@ func invShiftRows(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 }
 
 func mixColumns(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
-	f := func(t uint32) uint32 {
-		var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
-		d0 := mul2[b0] ^ mul3[b1] ^ b2 ^ b3
-		d1 := b0 ^ mul2[b1] ^ mul3[b2] ^ b3
-		d2 := b0 ^ b1 ^ mul2[b2] ^ mul3[b3]
-		d3 := mul3[b0] ^ b1 ^ b2 ^ mul2[b3]
-		return binary.BigEndian.Uint32([]byte{d0, d1, d2, d3})
-	}
-	return f(s0), f(s1), f(s2), f(s3)
+	return mixColumn(s0), mixColumn(s1), mixColumn(s2), mixColumn(s3)
 }
 
 func invMixColumns(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
-	f := func(t uint32) uint32 {
-		var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
-		d0 := mul14[b0] ^ mul11[b1] ^ mul13[b2] ^ mul9[b3]
-		d1 := mul9[b0] ^ mul14[b1] ^ mul11[b2] ^ mul13[b3]
-		d2 := mul13[b0] ^ mul9[b1] ^ mul14[b2] ^ mul11[b3]
-		d3 := mul11[b0] ^ mul13[b1] ^ mul9[b2] ^ mul14[b3]
-		return binary.BigEndian.Uint32([]byte{d0, d1, d2, d3})
-	}
-	return f(s0), f(s1), f(s2), f(s3)
+	return invMixColumn(s0), invMixColumn(s1), invMixColumn(s2), invMixColumn(s3)
+}
+
+func mixColumn(t uint32) uint32 {
+	var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
+	d0 := gf256.AESMul2[b0] ^ gf256.AESMul3[b1] ^ b2 ^ b3
+	d1 := b0 ^ gf256.AESMul2[b1] ^ gf256.AESMul3[b2] ^ b3
+	d2 := b0 ^ b1 ^ gf256.AESMul2[b2] ^ gf256.AESMul3[b3]
+	d3 := gf256.AESMul3[b0] ^ b1 ^ b2 ^ gf256.AESMul2[b3]
+	return uint32(d0)<<24 | uint32(d1)<<16 | uint32(d2)<<8 | uint32(d3)
+}
+
+func invMixColumn(t uint32) uint32 {
+	var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
+	d0 := gf256.AESMul14[b0] ^ gf256.AESMul11[b1] ^ gf256.AESMul13[b2] ^ gf256.AESMul9[b3]
+	d1 := gf256.AESMul9[b0] ^ gf256.AESMul14[b1] ^ gf256.AESMul11[b2] ^ gf256.AESMul13[b3]
+	d2 := gf256.AESMul13[b0] ^ gf256.AESMul9[b1] ^ gf256.AESMul14[b2] ^ gf256.AESMul11[b3]
+	d3 := gf256.AESMul11[b0] ^ gf256.AESMul13[b1] ^ gf256.AESMul9[b2] ^ gf256.AESMul14[b3]
+	return uint32(d0)<<24 | uint32(d1)<<16 | uint32(d2)<<8 | uint32(d3)
 }
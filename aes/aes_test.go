@@ -51,25 +51,163 @@ func TestKeyExpansion(t *testing.T) {
 	a.Equal(uint32(0xa0fafe17), w[4])
 }
 
+func TestKeyExpansion192(t *testing.T) {
+	a := require.New(t)
+	key := []byte{
+		0x8e, 0x73, 0xb0, 0xf7, 0xda, 0x0e, 0x64, 0x52, 0xc8, 0x10, 0xf3, 0x2b, 0x80, 0x90, 0x79, 0xe5,
+		0x62, 0xf8, 0xea, 0xd2, 0x52, 0x2c, 0x6b, 0x7b,
+	}
+	w := make([]uint32, 52)
+	keyExpansion(key, w)
+	a.Equal(uint32(0x8e73b0f7), w[0])
+	a.Equal(uint32(0x522c6b7b), w[5])
+	a.Equal(uint32(0xfe0c91f7), w[6])
+	a.Equal(uint32(0xe98ba06f), w[48])
+	a.Equal(uint32(0x01002202), w[51])
+}
+
+func TestKeyExpansion256(t *testing.T) {
+	a := require.New(t)
+	key := []byte{
+		0x60, 0x3d, 0xeb, 0x10, 0x15, 0xca, 0x71, 0xbe, 0x2b, 0x73, 0xae, 0xf0, 0x85, 0x7d, 0x77, 0x81,
+		0x1f, 0x35, 0x2c, 0x07, 0x3b, 0x61, 0x08, 0xd7, 0x2d, 0x98, 0x10, 0xa3, 0x09, 0x14, 0xdf, 0xf4,
+	}
+	w := make([]uint32, 60)
+	keyExpansion(key, w)
+	a.Equal(uint32(0x603deb10), w[0])
+	a.Equal(uint32(0x0914dff4), w[7])
+	a.Equal(uint32(0x9ba35411), w[8])
+	a.Equal(uint32(0xa8b09c1a), w[12])
+	a.Equal(uint32(0x706c631e), w[59])
+}
+
+// FIPS-197 Appendix C example vectors.
 func TestCipher(t *testing.T) {
 	a := require.New(t)
 	plaintext := []byte{
 		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd,
 		0xee, 0xff,
 	}
-	key := []byte{
-		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
-		0x0e, 0x0f,
+	tests := []struct {
+		key        []byte
+		ciphertext []byte
+	}{
+		{
+			key: []byte{
+				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+				0x0e, 0x0f,
+			},
+			ciphertext: []byte{
+				0x69, 0xc4, 0xe0, 0xd8, 0x6a, 0x7b, 0x04, 0x30, 0xd8, 0xcd, 0xb7, 0x80, 0x70, 0xb4,
+				0xc5, 0x5a,
+			},
+		},
+		{
+			key: []byte{
+				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+				0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+			},
+			ciphertext: []byte{
+				0xdd, 0xa9, 0x7c, 0xa4, 0x86, 0x4c, 0xdf, 0xe0, 0x6e, 0xaf, 0x70, 0xa0, 0xec, 0x0d,
+				0x71, 0x91,
+			},
+		},
+		{
+			key: []byte{
+				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+				0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+				0x1c, 0x1d, 0x1e, 0x1f,
+			},
+			ciphertext: []byte{
+				0x8e, 0xa2, 0xb7, 0xca, 0x51, 0x67, 0x45, 0xbf, 0xea, 0xfc, 0x49, 0x90, 0x4b, 0x49,
+				0x60, 0x89,
+			},
+		},
 	}
-	ciphertext := []byte{
-		0x69, 0xc4, 0xe0, 0xd8, 0x6a, 0x7b, 0x04, 0x30, 0xd8, 0xcd, 0xb7, 0x80, 0x70, 0xb4,
-		0xc5, 0x5a,
+	for _, tt := range tests {
+		w := make([]uint32, 4*(rounds(len(tt.key))+1))
+		dst := make([]byte, len(plaintext))
+		keyExpansion(tt.key, w)
+		encryptBlock(w, dst, plaintext)
+		a.Equal(tt.ciphertext, dst)
+		decrptyBlock(w, dst, tt.ciphertext)
+		a.Equal(plaintext, dst)
 	}
-	w := make([]uint32, 44)
-	dst := make([]byte, len(plaintext))
-	keyExpansion(key, w)
-	encryptBlock(w, dst, plaintext)
-	a.Equal(ciphertext, dst)
-	decrptyBlock(w, dst, ciphertext)
-	a.Equal(plaintext, dst)
+}
+
+func TestReducedCipher(t *testing.T) {
+	a := require.New(t)
+	key := unhex("000102030405060708090a0b0c0d0e0f")
+	plaintext := unhex("00112233445566778899aabbccddeeff")
+	// FIPS-197 Appendix C.1, round[r].start is the state after r full rounds.
+	starts := []string{
+		"89d810e8855ace682d1843d8cb128fe4",
+		"4915598f55e5d7a0daca94fa1f0a63f7",
+		"fa636a2825b339c940668a3157244d17",
+	}
+	dst := make([]byte, BlockSize)
+	for i, s := range starts {
+		c, err := NewReducedCipher(key, i+1, true)
+		a.NoError(err)
+		c.Encrypt(dst, plaintext)
+		a.Equal(unhex(s), dst)
+	}
+
+	for _, n := range []int{16, 24, 32} {
+		key := make([]byte, n)
+		for i := range key {
+			key[i] = byte(i)
+		}
+		full, err := NewCipher(key)
+		a.NoError(err)
+		c, err := NewReducedCipher(key, rounds(n), false)
+		a.NoError(err)
+		want := make([]byte, BlockSize)
+		full.Encrypt(want, plaintext)
+		c.Encrypt(dst, plaintext)
+		a.Equal(want, dst)
+
+		for nr := 1; nr <= 14; nr++ {
+			for _, finalMix := range []bool{false, true} {
+				c, err := NewReducedCipher(key, nr, finalMix)
+				a.NoError(err)
+				c.Encrypt(dst, plaintext)
+				a.NotEqual(plaintext, dst)
+				c.Decrypt(dst, dst)
+				a.Equal(plaintext, dst)
+			}
+		}
+	}
+
+	_, err := NewReducedCipher(key, 0, false)
+	a.Equal(RoundsError(0), err)
+	_, err = NewReducedCipher(key, 15, false)
+	a.Equal(RoundsError(15), err)
+	_, err = NewReducedCipher(key[:15], 4, false)
+	a.Equal(KeySizeError(15), err)
+}
+
+func TestReducedCipherBackends(t *testing.T) {
+	a := require.New(t)
+	key := unhex("000102030405060708090a0b0c0d0e0f1011121314151617")
+	src := unhex("00112233445566778899aabbccddeeff")
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	for nr := 1; nr <= 14; nr++ {
+		ref, err := NewReducedCipher(key, nr, false)
+		a.NoError(err)
+		ref.Encrypt(want, src)
+		for _, backend := range []Backend{TTable, Bitsliced} {
+			c, err := NewReducedCipherWithBackend(key, nr, false, backend)
+			a.NoError(err)
+			c.Encrypt(dst, src)
+			a.Equal(want, dst)
+			c.Decrypt(dst, dst)
+			a.Equal(src, dst)
+		}
+	}
+	_, err := NewReducedCipherWithBackend(key, 4, true, TTable)
+	a.Error(err)
+	_, err = NewReducedCipherWithBackend(key, 4, false, Backend(9))
+	a.Error(err)
 }
@@ -0,0 +1,215 @@
+package aes
+
+import (
+	"encoding/binary"
+
+	"github.com/RainbowDashy/cipher/internal/bitslice"
+)
+
+// The bitsliced backend keeps four blocks in eight uint64 planes. Bit k of
+// byte i of block b is bit 16*b+i of plane k, so that within each 16-bit
+// group bit 4*c+r holds row r of column c. Every step is a fixed sequence of
+// logic operations and shifts, without secret-dependent memory access.
+const bsBlocks = 4
+
+// bsInvSbox computes the inverse S-box as g(S(g(x))), where g(x) =
+// A^-1(x^0x63) undoes the S-box's affine map and S inverts in GF(2^8).
+func bsInvSbox(q *[8]uint64) {
+	bsInvAffine(q)
+	bitslice.Sbox(q)
+	bsInvAffine(q)
+}
+
+// bsInvAffine maps x to A^-1(x^0x63), i.e. bit i becomes
+// x[i+2]^x[i+5]^x[i+7]^0x05[i] with indices mod 8.
+func bsInvAffine(q *[8]uint64) {
+	var t [8]uint64
+	for i := 0; i < 8; i++ {
+		t[i] = q[(i+2)%8] ^ q[(i+5)%8] ^ q[(i+7)%8]
+	}
+	t[0] = ^t[0]
+	t[2] = ^t[2]
+	*q = t
+}
+
+// bsSubw is a constant-time subw for the key schedule.
+func bsSubw(t uint32) uint32 {
+	var q [8]uint64
+	for k := 0; k < 8; k++ {
+		for i := 0; i < 4; i++ {
+			q[k] |= uint64(t>>(8*i+k)&1) << i
+		}
+	}
+	bitslice.Sbox(&q)
+	t = 0
+	for k := 0; k < 8; k++ {
+		for i := 0; i < 4; i++ {
+			t |= uint32(q[k]>>i&1) << (8*i + k)
+		}
+	}
+	return t
+}
+
+// transpose8 transposes x as an 8x8 bit matrix whose rows are its
+// little-endian bytes.
+func transpose8(x uint64) uint64 {
+	t := (x ^ x>>7) & 0x00aa00aa00aa00aa
+	x ^= t ^ t<<7
+	t = (x ^ x>>14) & 0x0000cccc0000cccc
+	x ^= t ^ t<<14
+	t = (x ^ x>>28) & 0x00000000f0f0f0f0
+	x ^= t ^ t<<28
+	return x
+}
+
+// bsLoad packs up to four blocks from src into q. Missing blocks are zero.
+func bsLoad(q *[8]uint64, src []byte) {
+	*q = [8]uint64{}
+	for b := 0; b*BlockSize < len(src); b++ {
+		lo := transpose8(binary.LittleEndian.Uint64(src[16*b:]))
+		hi := transpose8(binary.LittleEndian.Uint64(src[16*b+8:]))
+		for k := 0; k < 8; k++ {
+			q[k] |= (lo>>(8*k)&0xff | hi>>(8*k)&0xff<<8) << (16 * b)
+		}
+	}
+}
+
+// bsStore unpacks q into as many blocks as dst holds.
+func bsStore(dst []byte, q *[8]uint64) {
+	for b := 0; b*BlockSize < len(dst); b++ {
+		var lo, hi uint64
+		for k := 0; k < 8; k++ {
+			p := q[k] >> (16 * b)
+			lo |= p & 0xff << (8 * k)
+			hi |= p >> 8 & 0xff << (8 * k)
+		}
+		binary.LittleEndian.PutUint64(dst[16*b:], transpose8(lo))
+		binary.LittleEndian.PutUint64(dst[16*b+8:], transpose8(hi))
+	}
+}
+
+// bsRoundKeys packs each round key of w and broadcasts it to all blocks.
+func bsRoundKeys(w []uint32, rk [][8]uint64) {
+	var b [BlockSize]byte
+	for r := range rk {
+		for j := 0; j < 4; j++ {
+			binary.BigEndian.PutUint32(b[4*j:], w[4*r+j])
+		}
+		bsLoad(&rk[r], b[:])
+		for k := range rk[r] {
+			rk[r][k] *= 0x0001000100010001
+		}
+	}
+}
+
+func bsAddRoundKey(q, rk *[8]uint64) {
+	for k := range q {
+		q[k] ^= rk[k]
+	}
+}
+
+// Masks selecting one row, or one row of a subset of columns, in every 16-bit
+// group.
+const (
+	bsRow0 = 0x1111111111111111
+	bsRep  = 0x0001000100010001
+)
+
+// bsShiftRows rotates row r of each block left by r columns, which is a
+// right rotation by 4*r bits of the row's bits in each 16-bit group.
+func bsShiftRows(q *[8]uint64) {
+	for k, x := range q {
+		q[k] = x&bsRow0 |
+			x>>4&(0x0222*bsRep) | x<<12&(0x2000*bsRep) |
+			x>>8&(0x0044*bsRep) | x<<8&(0x4400*bsRep) |
+			x>>12&(0x0008*bsRep) | x<<4&(0x8880*bsRep)
+	}
+}
+
+func bsInvShiftRows(q *[8]uint64) {
+	for k, x := range q {
+		q[k] = x&bsRow0 |
+			x<<4&(0x2220*bsRep) | x>>12&(0x0002*bsRep) |
+			x>>8&(0x0044*bsRep) | x<<8&(0x4400*bsRep) |
+			x<<12&(0x8000*bsRep) | x>>4&(0x0888*bsRep)
+	}
+}
+
+// bsRot1 and bsRot2 move row r+1 and row r+2 of each column into row r.
+func bsRot1(x uint64) uint64 {
+	return x>>1&0x7777777777777777 | x<<3&0x8888888888888888
+}
+
+func bsRot2(x uint64) uint64 {
+	return x>>2&0x3333333333333333 | x<<2&0xcccccccccccccccc
+}
+
+// bsXtime multiplies every byte by x in GF(2^8).
+func bsXtime(q *[8]uint64) {
+	hi := q[7]
+	q[7], q[6], q[5], q[4], q[3], q[2], q[1], q[0] = q[6], q[5], q[4], q[3]^hi, q[2]^hi, q[1], q[0]^hi, hi
+}
+
+// bsMixColumns computes 2*a[r] ^ 3*a[r+1] ^ a[r+2] ^ a[r+3] as
+// 2*t ^ a[r+1] ^ t[r+2] with t = a ^ a[r+1].
+func bsMixColumns(q *[8]uint64) {
+	var t [8]uint64
+	for k, x := range q {
+		t[k] = x ^ bsRot1(x)
+	}
+	t2 := t
+	bsXtime(&t2)
+	for k, x := range q {
+		q[k] = t2[k] ^ bsRot1(x) ^ bsRot2(t[k])
+	}
+}
+
+// bsInvMixColumns uses InvMixColumns = MixColumns * (5 0 4 0) circulant, where
+// the right factor adds 4*(a[r]^a[r+2]) to every row.
+func bsInvMixColumns(q *[8]uint64) {
+	var u [8]uint64
+	for k, x := range q {
+		u[k] = x ^ bsRot2(x)
+	}
+	bsXtime(&u)
+	bsXtime(&u)
+	for k := range q {
+		q[k] ^= u[k]
+	}
+	bsMixColumns(q)
+}
+
+// encryptBlocksBitsliced encrypts up to four blocks from src into dst.
+func encryptBlocksBitsliced(rk [][8]uint64, dst, src []byte) {
+	nr := len(rk) - 1
+	var q [8]uint64
+	bsLoad(&q, src)
+	bsAddRoundKey(&q, &rk[0])
+	for r := 1; r < nr; r++ {
+		bitslice.Sbox(&q)
+		bsShiftRows(&q)
+		bsMixColumns(&q)
+		bsAddRoundKey(&q, &rk[r])
+	}
+	bitslice.Sbox(&q)
+	bsShiftRows(&q)
+	bsAddRoundKey(&q, &rk[nr])
+	bsStore(dst, &q)
+}
+
+func decryptBlocksBitsliced(rk [][8]uint64, dst, src []byte) {
+	nr := len(rk) - 1
+	var q [8]uint64
+	bsLoad(&q, src)
+	bsAddRoundKey(&q, &rk[nr])
+	for r := nr - 1; r > 0; r-- {
+		bsInvShiftRows(&q)
+		bsInvSbox(&q)
+		bsAddRoundKey(&q, &rk[r])
+		bsInvMixColumns(&q)
+	}
+	bsInvShiftRows(&q)
+	bsInvSbox(&q)
+	bsAddRoundKey(&q, &rk[0])
+	bsStore(dst, &q)
+}
@@ -0,0 +1,106 @@
+package aes
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/RainbowDashy/cipher/internal/bitslice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBsSbox(t *testing.T) {
+	a := require.New(t)
+	for n := 0; n < 4; n++ {
+		var q, p [8]uint64
+		for k := 0; k < 8; k++ {
+			for j := 0; j < 64; j++ {
+				q[k] |= uint64((64*n+j)>>k&1) << j
+			}
+		}
+		p = q
+		bitslice.Sbox(&q)
+		bsInvSbox(&p)
+		for j := 0; j < 64; j++ {
+			var s, i byte
+			for k := 0; k < 8; k++ {
+				s |= byte(q[k]>>j&1) << k
+				i |= byte(p[k]>>j&1) << k
+			}
+			a.Equal(gf256.AESSBox[64*n+j], s)
+			a.Equal(gf256.AESInvSBox[64*n+j], i)
+		}
+	}
+	a.Equal(uint32(0x637c777b), bsSubw(0x00010203))
+}
+
+func TestBsLoadStore(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	src := make([]byte, bsBlocks*BlockSize)
+	dst := make([]byte, len(src))
+	rg.Read(src)
+	var q [8]uint64
+	bsLoad(&q, src)
+	for b := 0; b < bsBlocks; b++ {
+		for i := 0; i < BlockSize; i++ {
+			for k := 0; k < 8; k++ {
+				a.Equal(uint64(src[16*b+i]>>k&1), q[k]>>(16*b+i)&1)
+			}
+		}
+	}
+	bsStore(dst, &q)
+	a.Equal(src, dst)
+}
+
+func TestBitsliced(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < 100; i++ {
+		key := make([]byte, 16+8*(i%3))
+		rg.Read(key)
+		tt, err := NewCipherWithBackend(key, TTable)
+		a.NoError(err)
+		bs, err := NewCipherWithBackend(key, Bitsliced)
+		a.NoError(err)
+		a.Equal(tt.(*aesCipher).w, bs.(*aesCipher).w)
+
+		// Cover partial and multiple batches.
+		n := 1 + i%9
+		src := make([]byte, n*BlockSize)
+		want := make([]byte, len(src))
+		dst := make([]byte, len(src))
+		rg.Read(src)
+		for j := 0; j < len(src); j += BlockSize {
+			tt.Encrypt(want[j:], src[j:])
+		}
+		bs.(MultiBlock).EncryptBlocks(dst, src)
+		a.Equal(want, dst)
+		bs.Encrypt(dst, src)
+		a.Equal(want[:BlockSize], dst[:BlockSize])
+
+		for j := 0; j < len(src); j += BlockSize {
+			tt.Decrypt(want[j:], src[j:])
+		}
+		bs.(MultiBlock).DecryptBlocks(dst, src)
+		a.Equal(want, dst)
+		bs.Decrypt(dst, src)
+		a.Equal(want[:BlockSize], dst[:BlockSize])
+	}
+}
+
+func BenchmarkEncryptBitsliced(b *testing.B) { benchmarkBackend(b, Bitsliced, false) }
+func BenchmarkDecryptBitsliced(b *testing.B) { benchmarkBackend(b, Bitsliced, true) }
+
+func BenchmarkEncryptBlocksBitsliced(b *testing.B) {
+	c, err := NewCipherWithBackend(make([]byte, 16), Bitsliced)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 64*BlockSize)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		c.(MultiBlock).EncryptBlocks(buf, buf)
+	}
+}
@@ -0,0 +1,217 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+)
+
+// Padding selects how CBC handles messages that are not a whole number of
+// blocks.
+type Padding int
+
+const (
+	// NoPadding requires a whole number of blocks.
+	NoPadding Padding = iota
+	// PKCS7 appends 1 to 16 bytes, each holding the number of bytes added.
+	PKCS7
+	// CS1, CS2 and CS3 are the ciphertext stealing variants of the SP
+	// 800-38A addendum. They keep the ciphertext as long as the plaintext,
+	// which must be at least one block, and differ in where the partial
+	// block goes: CS1 keeps it second to last, CS3 always swaps it to the
+	// end, and CS2 swaps only when the last block is partial.
+	CS1
+	CS2
+	CS3
+)
+
+// LengthError is returned when an input length is not valid for the padding.
+type LengthError int
+
+func (l LengthError) Error() string {
+	return "aes: invalid input length " + strconv.Itoa(int(l))
+}
+
+// PaddingError is returned when decrypted PKCS#7 padding is malformed. It
+// carries no detail, to avoid becoming a padding oracle.
+type PaddingError struct{}
+
+func (PaddingError) Error() string {
+	return "aes: invalid padding"
+}
+
+// CBC is cipher block chaining over a 16-byte block cipher with a fixed IV.
+type CBC struct {
+	b       cipher.Block
+	iv      [BlockSize]byte
+	padding Padding
+}
+
+// NewCBC returns CBC mode with the given IV and padding.
+func NewCBC(b cipher.Block, iv []byte, padding Padding) (*CBC, error) {
+	if b.BlockSize() != BlockSize {
+		return nil, errors.New("aes: CBC requires a 16-byte block cipher")
+	}
+	if len(iv) != BlockSize {
+		return nil, errors.New("aes: IV length must equal block size")
+	}
+	if padding < NoPadding || padding > CS3 {
+		return nil, errors.New("aes: unknown padding")
+	}
+	c := &CBC{b: b, padding: padding}
+	copy(c.iv[:], iv)
+	return c, nil
+}
+
+// Encrypt returns the encryption of plaintext.
+func (c *CBC) Encrypt(plaintext []byte) ([]byte, error) {
+	n := len(plaintext)
+	switch c.padding {
+	case NoPadding:
+		if n%BlockSize != 0 {
+			return nil, LengthError(n)
+		}
+		out := append([]byte(nil), plaintext...)
+		c.encrypt(out)
+		return out, nil
+	case PKCS7:
+		p := BlockSize - n%BlockSize
+		out := make([]byte, n+p)
+		copy(out, plaintext)
+		for i := n; i < len(out); i++ {
+			out[i] = byte(p)
+		}
+		c.encrypt(out)
+		return out, nil
+	}
+
+	if n < BlockSize {
+		return nil, LengthError(n)
+	}
+	buf := make([]byte, (n+BlockSize-1)/BlockSize*BlockSize)
+	copy(buf, plaintext)
+	c.encrypt(buf)
+	if len(buf) == BlockSize {
+		return buf, nil
+	}
+	// buf ends with the full C[n-1] and C[n]; the stolen tail of C[n-1]
+	// is dropped.
+	k := len(buf) - 2*BlockSize
+	d := n - k - BlockSize
+	out := make([]byte, n)
+	copy(out, buf[:k])
+	if c.swapped(d) {
+		copy(out[k:], buf[k+BlockSize:])
+		copy(out[k+BlockSize:], buf[k:k+d])
+	} else {
+		copy(out[k:], buf[k:k+d])
+		copy(out[k+d:], buf[k+BlockSize:])
+	}
+	return out, nil
+}
+
+// Decrypt returns the decryption of ciphertext.
+func (c *CBC) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := len(ciphertext)
+	switch c.padding {
+	case NoPadding, PKCS7:
+		if n%BlockSize != 0 || c.padding == PKCS7 && n == 0 {
+			return nil, LengthError(n)
+		}
+		out := make([]byte, n)
+		c.decrypt(out, ciphertext)
+		if c.padding == NoPadding {
+			return out, nil
+		}
+		p, ok := unpad(out)
+		if !ok {
+			return nil, PaddingError{}
+		}
+		return out[:n-p], nil
+	}
+
+	if n < BlockSize {
+		return nil, LengthError(n)
+	}
+	if n == BlockSize {
+		out := make([]byte, n)
+		c.decrypt(out, ciphertext)
+		return out, nil
+	}
+	k := (n - 1) / BlockSize * BlockSize
+	k -= BlockSize
+	d := n - k - BlockSize
+
+	// Rebuild C[n-1] from its d-byte prefix and the tail of D(C[n]), which
+	// is C[n-1] XORed with the zero padding of the last block.
+	buf := make([]byte, k+2*BlockSize)
+	copy(buf, ciphertext[:k])
+	if c.swapped(d) {
+		copy(buf[k:], ciphertext[k+BlockSize:])
+		copy(buf[k+BlockSize:], ciphertext[k:k+BlockSize])
+	} else {
+		copy(buf[k:], ciphertext[k:k+d])
+		copy(buf[k+BlockSize:], ciphertext[k+d:])
+	}
+	var z [BlockSize]byte
+	c.b.Decrypt(z[:], buf[k+BlockSize:])
+	copy(buf[k+d:k+BlockSize], z[d:])
+
+	out := make([]byte, k+2*BlockSize)
+	c.decrypt(out[:k+BlockSize], buf[:k+BlockSize])
+	for i := 0; i < d; i++ {
+		out[k+BlockSize+i] = z[i] ^ buf[k+i]
+	}
+	return out[:n], nil
+}
+
+// swapped reports whether the last two ciphertext blocks are swapped when the
+// last plaintext block holds d bytes.
+func (c *CBC) swapped(d int) bool {
+	return c.padding == CS3 || c.padding == CS2 && d < BlockSize
+}
+
+// encrypt runs CBC over the whole blocks of buf in place.
+func (c *CBC) encrypt(buf []byte) {
+	prev := c.iv[:]
+	for i := 0; i < len(buf); i += BlockSize {
+		b := buf[i : i+BlockSize]
+		for j := range b {
+			b[j] ^= prev[j]
+		}
+		c.b.Encrypt(b, b)
+		prev = b
+	}
+}
+
+// decrypt runs CBC decryption of src into dst, which must not overlap. The
+// block decryptions are independent, so they go through MultiBlock when the
+// cipher has it.
+func (c *CBC) decrypt(dst, src []byte) {
+	if m, ok := c.b.(MultiBlock); ok {
+		m.DecryptBlocks(dst, src)
+	} else {
+		for i := 0; i < len(src); i += BlockSize {
+			c.b.Decrypt(dst[i:], src[i:])
+		}
+	}
+	for i := 0; i < BlockSize; i++ {
+		dst[i] ^= c.iv[i]
+	}
+	for i := BlockSize; i < len(src); i++ {
+		dst[i] ^= src[i-BlockSize]
+	}
+}
+
+// unpad checks PKCS#7 padding in constant time and returns its length.
+func unpad(b []byte) (int, bool) {
+	p := b[len(b)-1]
+	good := subtle.ConstantTimeLessOrEq(1, int(p)) & subtle.ConstantTimeLessOrEq(int(p), BlockSize)
+	for i := 1; i <= BlockSize; i++ {
+		in := subtle.ConstantTimeLessOrEq(i, int(p))
+		eq := subtle.ConstantTimeByteEq(b[len(b)-i], p)
+		good &= eq | (in ^ 1)
+	}
+	return int(p), good == 1
+}
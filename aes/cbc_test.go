@@ -0,0 +1,215 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// SP 800-38A F.2.1, F.2.3 and F.2.5.
+func TestCBCVectors(t *testing.T) {
+	a := require.New(t)
+	iv := unhex("000102030405060708090a0b0c0d0e0f")
+	pt := unhex("6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		key, ct string
+	}{
+		{
+			"2b7e151628aed2a6abf7158809cf4f3c",
+			"7649abac8119b246cee98e9b12e9197d" +
+				"5086cb9b507219ee95db113a917678b2" +
+				"73bed6b8e3c1743b7116e69e22229516" +
+				"3ff1caa1681fac09120eca307586e1a7",
+		},
+		{
+			"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+			"4f021db243bc633d7178183a9fa071e8" +
+				"b4d9ada9ad7dedf4e5e738763f69145a" +
+				"571b242012fb7ae07fa9baac3df102e0" +
+				"08b0e27988598881d920a9e64f5615cd",
+		},
+		{
+			"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+			"f58c4c04d6e5f1ba779eabfb5f7bfbd6" +
+				"9cfc4e967edb808d679f777bc6702c7d" +
+				"39f23369a9d9bacfa530e26304231461" +
+				"b2eb05e2c39be9fcda6c19078c6a9d1b",
+		},
+	}
+	for _, test := range tests {
+		for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+			b, err := NewCipherWithBackend(unhex(test.key), backend)
+			a.NoError(err)
+			// CS1 and CS2 are plain CBC on whole blocks.
+			for _, padding := range []Padding{NoPadding, CS1, CS2} {
+				c, err := NewCBC(b, iv, padding)
+				a.NoError(err)
+				ct, err := c.Encrypt(pt)
+				a.NoError(err)
+				a.Equal(unhex(test.ct), ct)
+				got, err := c.Decrypt(ct)
+				a.NoError(err)
+				a.Equal(pt, got)
+			}
+		}
+	}
+}
+
+// The CS3 examples of RFC 3962 Appendix B, which uses CBC-CS3 with a zero IV.
+func TestCBCCS3Vectors(t *testing.T) {
+	a := require.New(t)
+	b, err := NewCipher(unhex("636869636b656e207465726979616b69"))
+	a.NoError(err)
+	c, err := NewCBC(b, make([]byte, BlockSize), CS3)
+	a.NoError(err)
+	tests := []struct {
+		pt, ct string
+	}{
+		{
+			"4920776f756c64206c696b652074686520",
+			"c6353568f2bf8cb4d8a580362da7ff7f97",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320",
+			"fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c2047617527732043",
+			"39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c",
+			"97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20",
+			"97687268d6ecccc0c07b25e25ecfe5849dad8bbb96c4cdc03bc103e1a194bbd839312523a78662d5be7fcbcc98ebf5a8",
+		},
+		{
+			"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20616e6420776f6e746f6e20736f75702e",
+			"97687268d6ecccc0c07b25e25ecfe58439312523a78662d5be7fcbcc98ebf5a84807efe836ee89a526730dbc2f7bc8409dad8bbb96c4cdc03bc103e1a194bbd8",
+		},
+	}
+	for _, test := range tests {
+		ct, err := c.Encrypt(unhex(test.pt))
+		a.NoError(err)
+		a.Equal(unhex(test.ct), ct)
+		pt, err := c.Decrypt(ct)
+		a.NoError(err)
+		a.Equal(unhex(test.pt), pt)
+	}
+}
+
+func TestCBCStealing(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	iv := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(iv)
+	b, err := NewCipher(key)
+	a.NoError(err)
+	cs := map[Padding]*CBC{}
+	for _, p := range []Padding{CS1, CS2, CS3} {
+		cs[p], err = NewCBC(b, iv, p)
+		a.NoError(err)
+	}
+	for n := BlockSize; n < 5*BlockSize; n++ {
+		pt := make([]byte, n)
+		rg.Read(pt)
+		ct := map[Padding][]byte{}
+		for p, c := range cs {
+			ct[p], err = c.Encrypt(pt)
+			a.NoError(err)
+			a.Len(ct[p], n)
+			got, err := c.Decrypt(ct[p])
+			a.NoError(err)
+			a.Equal(pt, got)
+		}
+
+		// CS1 is CBC over the zero-padded message with C[n-1] truncated.
+		padded := make([]byte, (n+BlockSize-1)/BlockSize*BlockSize)
+		copy(padded, pt)
+		full := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(b, iv).CryptBlocks(full, padded)
+		d := n % BlockSize
+		if d == 0 || n == BlockSize {
+			a.Equal(full, ct[CS1])
+			a.Equal(full, ct[CS2])
+			continue
+		}
+		k := len(full) - 2*BlockSize
+		a.Equal(full[:k+d], ct[CS1][:k+d])
+		a.Equal(full[k+BlockSize:], ct[CS1][k+d:])
+		a.Equal(ct[CS2], ct[CS3])
+	}
+
+	for _, c := range cs {
+		_, err := c.Encrypt(make([]byte, BlockSize-1))
+		a.Equal(LengthError(BlockSize-1), err)
+		_, err = c.Decrypt(nil)
+		a.Equal(LengthError(0), err)
+	}
+}
+
+func TestCBCPKCS7(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 24)
+	iv := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(iv)
+	b, err := NewCipherWithBackend(key, Bitsliced)
+	a.NoError(err)
+	c, err := NewCBC(b, iv, PKCS7)
+	a.NoError(err)
+	for n := 0; n < 4*BlockSize; n++ {
+		pt := make([]byte, n)
+		rg.Read(pt)
+		ct, err := c.Encrypt(pt)
+		a.NoError(err)
+		a.Len(ct, n/BlockSize*BlockSize+BlockSize)
+
+		raw := make([]byte, len(ct))
+		cipher.NewCBCDecrypter(b, iv).CryptBlocks(raw, ct)
+		p := len(ct) - n
+		a.Equal(bytes.Repeat([]byte{byte(p)}, p), raw[n:])
+
+		got, err := c.Decrypt(ct)
+		a.NoError(err)
+		a.Equal(pt, got)
+	}
+
+	bad := func(pad []byte) []byte {
+		raw := make([]byte, 2*BlockSize)
+		rg.Read(raw)
+		copy(raw[len(raw)-len(pad):], pad)
+		ct := make([]byte, len(raw))
+		cipher.NewCBCEncrypter(b, iv).CryptBlocks(ct, raw)
+		return ct
+	}
+	for _, pad := range [][]byte{{0}, {17}, {0xff}, {1, 2}, {3, 3, 3, 3, 5, 3, 3}} {
+		_, err := c.Decrypt(bad(pad))
+		var perr PaddingError
+		a.True(errors.As(err, &perr))
+	}
+	_, err = c.Decrypt(make([]byte, 20))
+	a.Equal(LengthError(20), err)
+	_, err = c.Decrypt(nil)
+	a.Equal(LengthError(0), err)
+
+	none, err := NewCBC(b, iv, NoPadding)
+	a.NoError(err)
+	_, err = none.Encrypt(make([]byte, 17))
+	a.Equal(LengthError(17), err)
+	_, err = NewCBC(b, iv, Padding(-1))
+	a.Error(err)
+}
@@ -0,0 +1,152 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// ccm is CCM from SP 800-38C and RFC 3610.
+type ccm struct {
+	b cipher.Block
+	// tagSize is M and lengthSize L in RFC 3610. The nonce takes the
+	// remaining 15-L bytes of a counter block.
+	tagSize    int
+	lengthSize int
+}
+
+// NewCCM returns b in CCM mode with tags of tagSize bytes (4 to 16, even) and
+// a message length field of lengthSize bytes (2 to 8), which limits messages
+// to 2^(8*lengthSize)-1 bytes and sets the nonce size to 15-lengthSize.
+func NewCCM(b cipher.Block, tagSize, lengthSize int) (cipher.AEAD, error) {
+	if b.BlockSize() != BlockSize {
+		return nil, errors.New("aes: CCM requires a 16-byte block cipher")
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, errors.New("aes: invalid CCM tag size")
+	}
+	if lengthSize < 2 || lengthSize > 8 {
+		return nil, errors.New("aes: invalid CCM length size")
+	}
+	return &ccm{b: b, tagSize: tagSize, lengthSize: lengthSize}, nil
+}
+
+func (c *ccm) NonceSize() int {
+	return 15 - c.lengthSize
+}
+
+func (c *ccm) Overhead() int {
+	return c.tagSize
+}
+
+// maxLen returns the longest message the length field can hold.
+func (c *ccm) maxLen() uint64 {
+	if c.lengthSize == 8 {
+		return 1<<64 - 1
+	}
+	return 1<<(8*c.lengthSize) - 1
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.NonceSize() {
+		panic("aes: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > c.maxLen() {
+		panic("aes: message too large for CCM")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	tag := c.mac(nonce, plaintext, additionalData)
+	ctr := c.counter(nonce)
+	ctr.XORKeyStream(tag[:], tag[:])
+	ctr.XORKeyStream(out, plaintext)
+	copy(out[len(plaintext):], tag[:c.tagSize])
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.NonceSize() {
+		panic("aes: incorrect nonce length given to CCM")
+	}
+	if len(ciphertext) < c.tagSize || uint64(len(ciphertext)-c.tagSize) > c.maxLen() {
+		return nil, errOpen
+	}
+	tag := ciphertext[len(ciphertext)-c.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	// The MAC covers the plaintext, so decrypt into a scratch buffer and
+	// only hand it out once the tag checks.
+	ctr := c.counter(nonce)
+	var mask [BlockSize]byte
+	ctr.XORKeyStream(mask[:], mask[:])
+	pt := make([]byte, len(ciphertext))
+	ctr.XORKeyStream(pt, ciphertext)
+	want := c.mac(nonce, pt, additionalData)
+	for i := range want {
+		want[i] ^= mask[i]
+	}
+	if subtle.ConstantTimeCompare(want[:c.tagSize], tag) != 1 {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(pt))
+	copy(out, pt)
+	return ret, nil
+}
+
+// counter returns the keystream from counter block A0, whose first block
+// masks the tag and whose remainder encrypts the message.
+func (c *ccm) counter(nonce []byte) *CTR {
+	var a0 [BlockSize]byte
+	a0[0] = byte(c.lengthSize - 1)
+	copy(a0[1:], nonce)
+	ctr, err := NewCTRWithCounter(c.b, a0[:], BlockSize-c.lengthSize, c.lengthSize)
+	if err != nil {
+		panic(err)
+	}
+	return ctr
+}
+
+// mac computes the CBC-MAC over B0, the encoded additional data and the
+// plaintext, each zero-padded to whole blocks.
+func (c *ccm) mac(nonce, plaintext, additionalData []byte) [BlockSize]byte {
+	var y [BlockSize]byte
+	y[0] = byte((c.tagSize-2)/2<<3 | (c.lengthSize - 1))
+	if len(additionalData) > 0 {
+		y[0] |= 1 << 6
+	}
+	copy(y[1:], nonce)
+	for i, n := BlockSize-1, uint64(len(plaintext)); i > len(nonce); i-- {
+		y[i] = byte(n)
+		n >>= 8
+	}
+	c.b.Encrypt(y[:], y[:])
+
+	if n := uint64(len(additionalData)); n > 0 {
+		var hdr []byte
+		switch {
+		case n < 1<<16-1<<8:
+			hdr = []byte{byte(n >> 8), byte(n)}
+		case n <= 1<<32-1:
+			hdr = []byte{0xff, 0xfe, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+		default:
+			hdr = []byte{0xff, 0xff, byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32),
+				byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+		}
+		c.cbcMAC(&y, append(hdr, additionalData...))
+	}
+	c.cbcMAC(&y, plaintext)
+	return y
+}
+
+func (c *ccm) cbcMAC(y *[BlockSize]byte, data []byte) {
+	for len(data) > 0 {
+		n := BlockSize
+		if len(data) < n {
+			n = len(data)
+		}
+		for i := 0; i < n; i++ {
+			y[i] ^= data[i]
+		}
+		data = data[n:]
+		c.b.Encrypt(y[:], y[:])
+	}
+}
@@ -0,0 +1,111 @@
+package aes
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCCMVectors(t *testing.T) {
+	a := require.New(t)
+	tests := []struct {
+		key              string
+		tagSize, lenSize int
+		nonce, ad, pt    string
+		ct               string
+	}{
+		// RFC 3610 packet vectors 1 to 3.
+		{
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf", 8, 2,
+			"00000003020100a0a1a2a3a4a5", "0001020304050607",
+			"08090a0b0c0d0e0f101112131415161718191a1b1c1d1e",
+			"588c979a61c663d2f066d0c2c0f989806d5f6b61dac384" + "17e8d12cfdf926e0",
+		},
+		{
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf", 8, 2,
+			"00000004030201a0a1a2a3a4a5", "0001020304050607",
+			"08090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			"72c91a36e135f8cf291ca894085c87e3cc15c439c9e43a3b" + "a091d56e10400916",
+		},
+		{
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf", 8, 2,
+			"00000005040302a0a1a2a3a4a5", "0001020304050607",
+			"08090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20",
+			"51b1e5f44a197d1da46b0f8e2d282ae871e838bb64da859657" + "4adaa76fbd9fb0c5",
+		},
+		// SP 800-38C Appendix C examples 1 to 3.
+		{
+			"404142434445464748494a4b4c4d4e4f", 4, 8,
+			"10111213141516", "0001020304050607",
+			"20212223",
+			"7162015b4dac255d",
+		},
+		{
+			"404142434445464748494a4b4c4d4e4f", 6, 7,
+			"1011121314151617", "000102030405060708090a0b0c0d0e0f",
+			"202122232425262728292a2b2c2d2e2f",
+			"d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd",
+		},
+		{
+			"404142434445464748494a4b4c4d4e4f", 8, 3,
+			"101112131415161718191a1b", "000102030405060708090a0b0c0d0e0f10111213",
+			"202122232425262728292a2b2c2d2e2f3031323334353637",
+			"e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5" + "484392fbc1b09951",
+		},
+	}
+	for _, test := range tests {
+		b, err := NewCipher(unhex(test.key))
+		a.NoError(err)
+		c, err := NewCCM(b, test.tagSize, test.lenSize)
+		a.NoError(err)
+		a.Equal(len(test.nonce)/2, c.NonceSize())
+		ct := c.Seal(nil, unhex(test.nonce), unhex(test.pt), unhex(test.ad))
+		a.Equal(unhex(test.ct), ct)
+		pt, err := c.Open(nil, unhex(test.nonce), ct, unhex(test.ad))
+		a.NoError(err)
+		a.Equal(unhex(test.pt), pt)
+	}
+}
+
+func TestCCM(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 32)
+	rg.Read(key)
+	b, err := NewCipherWithBackend(key, TTable)
+	a.NoError(err)
+	for tagSize := 4; tagSize <= 16; tagSize += 2 {
+		for lenSize := 2; lenSize <= 8; lenSize++ {
+			c, err := NewCCM(b, tagSize, lenSize)
+			a.NoError(err)
+			a.Equal(tagSize, c.Overhead())
+			nonce := make([]byte, c.NonceSize())
+			pt := make([]byte, rg.Intn(100))
+			ad := make([]byte, rg.Intn(3)*rg.Intn(300))
+			rg.Read(nonce)
+			rg.Read(pt)
+			rg.Read(ad)
+			ct := c.Seal(nil, nonce, pt, ad)
+			a.Len(ct, len(pt)+tagSize)
+			got, err := c.Open([]byte("x"), nonce, ct, ad)
+			a.NoError(err)
+			a.True(bytes.Equal(append([]byte("x"), pt...), got))
+
+			ct[rg.Intn(len(ct))] ^= 1
+			_, err = c.Open(nil, nonce, ct, ad)
+			a.Error(err)
+		}
+	}
+
+	for _, p := range [][2]int{{3, 2}, {5, 2}, {18, 2}, {8, 1}, {8, 9}} {
+		_, err := NewCCM(b, p[0], p[1])
+		a.Error(err)
+	}
+	c, err := NewCCM(b, 16, 2)
+	a.NoError(err)
+	a.Panics(func() { c.Seal(nil, make([]byte, 13), make([]byte, 1<<16), nil) })
+	a.Panics(func() { c.Seal(nil, make([]byte, 12), nil, nil) })
+}
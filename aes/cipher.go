@@ -0,0 +1,226 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"strconv"
+
+	"github.com/RainbowDashy/cipher/trace"
+)
+
+// BlockSize is the AES block size in bytes.
+const BlockSize = 16
+
+// KeySizeError is returned by NewCipher when the key length is not supported.
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "aes: invalid key size " + strconv.Itoa(int(k))
+}
+
+// RoundsError is returned by NewReducedCipher when the round count is not
+// between 1 and 14.
+type RoundsError int
+
+func (r RoundsError) Error() string {
+	return "aes: invalid round count " + strconv.Itoa(int(r))
+}
+
+// Backend selects the implementation behind a cipher.Block.
+type Backend int
+
+const (
+	// Reference runs SubBytes, ShiftRows and MixColumns as separate steps.
+	Reference Backend = iota
+	// TTable merges the steps of each round into four table lookups per
+	// column.
+	TTable
+	// Bitsliced runs a constant-time bitsliced circuit on four blocks at
+	// once, key schedule included.
+	Bitsliced
+)
+
+// MultiBlock is implemented by the cipher.Block values of this package. The
+// Bitsliced backend processes four blocks per pass, so passing several blocks
+// at once is faster than calling Encrypt or Decrypt on each.
+type MultiBlock interface {
+	// EncryptBlocks encrypts src into dst. Both must be a multiple of
+	// BlockSize long and overlap entirely or not at all.
+	EncryptBlocks(dst, src []byte)
+
+	// DecryptBlocks decrypts src into dst like EncryptBlocks.
+	DecryptBlocks(dst, src []byte)
+}
+
+type aesCipher struct {
+	backend Backend
+	// finalMix keeps MixColumns in the last round of a reduced cipher.
+	finalMix bool
+	// tracer receives every step of the Reference path if set.
+	tracer trace.Tracer
+	w      []uint32
+	// dw is the equivalent inverse cipher schedule used by TTable.
+	dw []uint32
+	// rk holds the round keys packed for Bitsliced.
+	rk [][8]uint64
+}
+
+var _ MultiBlock = (*aesCipher)(nil)
+
+// NewCipher creates a cipher.Block from key using the Reference backend. The
+// key must be 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256.
+func NewCipher(key []byte) (cipher.Block, error) {
+	return NewCipherWithBackend(key, Reference)
+}
+
+// NewCipherWithBackend is NewCipher with an explicit backend.
+func NewCipherWithBackend(key []byte, backend Backend) (cipher.Block, error) {
+	nr := rounds(len(key))
+	if nr == 0 {
+		return nil, KeySizeError(len(key))
+	}
+	return newCipher(key, nr, false, backend)
+}
+
+// NewReducedCipher creates a cipher.Block that runs nr rounds of AES, for nr
+// from 1 to 14, using the Reference backend. The round keys come from the
+// usual key expansion for the key size, continued past its standard length
+// when nr is larger. If finalMix is set the last round keeps MixColumns.
+func NewReducedCipher(key []byte, nr int, finalMix bool) (cipher.Block, error) {
+	return NewReducedCipherWithBackend(key, nr, finalMix, Reference)
+}
+
+// NewReducedCipherWithBackend is NewReducedCipher with an explicit backend.
+// Only Reference supports finalMix.
+func NewReducedCipherWithBackend(key []byte, nr int, finalMix bool, backend Backend) (cipher.Block, error) {
+	if rounds(len(key)) == 0 {
+		return nil, KeySizeError(len(key))
+	}
+	if nr < 1 || nr > 14 {
+		return nil, RoundsError(nr)
+	}
+	if finalMix && backend != Reference {
+		return nil, errors.New("aes: final MixColumns needs the Reference backend")
+	}
+	return newCipher(key, nr, finalMix, backend)
+}
+
+func newCipher(key []byte, nr int, finalMix bool, backend Backend) (cipher.Block, error) {
+	c := &aesCipher{
+		backend:  backend,
+		finalMix: finalMix,
+		w:        make([]uint32, 4*(nr+1)),
+	}
+	switch backend {
+	case Reference:
+		keyExpansion(key, c.w)
+	case TTable:
+		keyExpansion(key, c.w)
+		c.dw = make([]uint32, len(c.w))
+		invKeySchedule(c.w, c.dw)
+	case Bitsliced:
+		expandKey(key, c.w, bsSubw)
+		c.rk = make([][8]uint64, nr+1)
+		bsRoundKeys(c.w, c.rk)
+	default:
+		return nil, errors.New("aes: unknown backend")
+	}
+	return c, nil
+}
+
+// WithTracer returns a copy of b, which must come from this package, that
+// reports every step of Encrypt and Decrypt to tr. The copy always runs the
+// Reference backend, so its output matches b whatever backend b uses.
+func WithTracer(b cipher.Block, tr trace.Tracer) (cipher.Block, error) {
+	c, ok := b.(*aesCipher)
+	if !ok {
+		return nil, errors.New("aes: block not created by this package")
+	}
+	t := *c
+	t.backend = Reference
+	t.tracer = tr
+	return &t, nil
+}
+
+func (c *aesCipher) BlockSize() int {
+	return BlockSize
+}
+
+func (c *aesCipher) Encrypt(dst, src []byte) {
+	if len(src) < BlockSize {
+		panic("aes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("aes: output not full block")
+	}
+	switch c.backend {
+	case TTable:
+		encryptBlockTTable(c.w, dst, src)
+	case Bitsliced:
+		encryptBlocksBitsliced(c.rk, dst[:BlockSize], src[:BlockSize])
+	default:
+		encryptRounds(c.w, c.finalMix, c.tracer, dst, src)
+	}
+}
+
+func (c *aesCipher) Decrypt(dst, src []byte) {
+	if len(src) < BlockSize {
+		panic("aes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("aes: output not full block")
+	}
+	switch c.backend {
+	case TTable:
+		decryptBlockTTable(c.dw, dst, src)
+	case Bitsliced:
+		decryptBlocksBitsliced(c.rk, dst[:BlockSize], src[:BlockSize])
+	default:
+		decryptRounds(c.w, c.finalMix, c.tracer, dst, src)
+	}
+}
+
+func (c *aesCipher) EncryptBlocks(dst, src []byte) {
+	c.checkBlocks(dst, src)
+	if c.backend == Bitsliced {
+		for len(src) > 0 {
+			n := bsBlocks * BlockSize
+			if len(src) < n {
+				n = len(src)
+			}
+			encryptBlocksBitsliced(c.rk, dst[:n], src[:n])
+			dst, src = dst[n:], src[n:]
+		}
+		return
+	}
+	for i := 0; i < len(src); i += BlockSize {
+		c.Encrypt(dst[i:], src[i:])
+	}
+}
+
+func (c *aesCipher) DecryptBlocks(dst, src []byte) {
+	c.checkBlocks(dst, src)
+	if c.backend == Bitsliced {
+		for len(src) > 0 {
+			n := bsBlocks * BlockSize
+			if len(src) < n {
+				n = len(src)
+			}
+			decryptBlocksBitsliced(c.rk, dst[:n], src[:n])
+			dst, src = dst[n:], src[n:]
+		}
+		return
+	}
+	for i := 0; i < len(src); i += BlockSize {
+		c.Decrypt(dst[i:], src[i:])
+	}
+}
+
+func (c *aesCipher) checkBlocks(dst, src []byte) {
+	if len(src)%BlockSize != 0 {
+		panic("aes: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("aes: output smaller than input")
+	}
+}
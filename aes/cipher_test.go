@@ -0,0 +1,148 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCipher(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	src := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	want := make([]byte, BlockSize)
+	for i := 0; i < 300; i++ {
+		key := make([]byte, 16+8*(i%3))
+		rg.Read(key)
+		rg.Read(src)
+		c, err := NewCipher(key)
+		a.NoError(err)
+		a.Equal(BlockSize, c.BlockSize())
+		std, err := aes.NewCipher(key)
+		a.NoError(err)
+
+		c.Encrypt(dst, src)
+		std.Encrypt(want, src)
+		a.Equal(want, dst)
+
+		c.Decrypt(dst, src)
+		std.Decrypt(want, src)
+		a.Equal(want, dst)
+	}
+}
+
+func TestNewCipherKeySize(t *testing.T) {
+	a := require.New(t)
+	for _, n := range []int{0, 1, 15, 17, 20, 31, 33, 64} {
+		c, err := NewCipher(make([]byte, n))
+		a.Nil(c)
+		a.Equal(KeySizeError(n), err)
+	}
+}
+
+func TestCipherModes(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	iv := make([]byte, BlockSize)
+	plaintext := make([]byte, 16*BlockSize)
+	rg.Read(key)
+	rg.Read(iv)
+	rg.Read(plaintext)
+
+	c, err := NewCipher(key)
+	a.NoError(err)
+	std, err := aes.NewCipher(key)
+	a.NoError(err)
+
+	got := make([]byte, len(plaintext))
+	want := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(c, iv).CryptBlocks(got, plaintext)
+	cipher.NewCBCEncrypter(std, iv).CryptBlocks(want, plaintext)
+	a.Equal(want, got)
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(got, want)
+	a.Equal(plaintext, got)
+
+	cipher.NewCTR(c, iv).XORKeyStream(got, plaintext)
+	cipher.NewCTR(std, iv).XORKeyStream(want, plaintext)
+	a.Equal(want, got)
+}
+
+func TestWithTracer(t *testing.T) {
+	a := require.New(t)
+	key := unhex("000102030405060708090a0b0c0d0e0f")
+	plaintext := unhex("00112233445566778899aabbccddeeff")
+	var lines []string
+	tr := trace.Func(func(round int, step string, state []byte) {
+		lines = append(lines, fmt.Sprintf("round[%2d].%-9s%x", round, step, state))
+	})
+	for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+		lines = lines[:0]
+		b, err := NewCipherWithBackend(key, backend)
+		a.NoError(err)
+		c, err := WithTracer(b, tr)
+		a.NoError(err)
+		dst := make([]byte, BlockSize)
+		c.Encrypt(dst, plaintext)
+		a.Equal(unhex("69c4e0d86a7b0430d8cdb78070b4c55a"), dst)
+		// FIPS-197 Appendix C.1.
+		a.Len(lines, 2+4*10+9+1)
+		a.Equal("round[ 0].input    00112233445566778899aabbccddeeff", lines[0])
+		a.Equal("round[ 0].k_sch    000102030405060708090a0b0c0d0e0f", lines[1])
+		a.Equal("round[ 1].start    00102030405060708090a0b0c0d0e0f0", lines[2])
+		a.Equal("round[ 1].s_box    63cab7040953d051cd60e0e7ba70e18c", lines[3])
+		a.Equal("round[ 1].s_row    6353e08c0960e104cd70b751bacad0e7", lines[4])
+		a.Equal("round[ 1].m_col    5f72641557f5bc92f7be3b291db9f91a", lines[5])
+		a.Equal("round[ 1].k_sch    d6aa74fdd2af72fadaa678f1d6ab76fe", lines[6])
+		a.Equal("round[10].k_sch    13111d7fe3944a17f307a78b4d2b30c5", lines[len(lines)-2])
+		a.Equal("round[10].output   69c4e0d86a7b0430d8cdb78070b4c55a", lines[len(lines)-1])
+
+		lines = lines[:0]
+		c.Decrypt(dst, dst)
+		a.Equal(plaintext, dst)
+		a.Len(lines, 2+5*10-1+1)
+		a.Equal("round[ 0].iinput   69c4e0d86a7b0430d8cdb78070b4c55a", lines[0])
+		a.Equal("round[ 1].istart   7ad5fda789ef4e272bca100b3d9ff59f", lines[2])
+		a.Equal("round[ 1].is_box   bd6e7c3df2b5779e0b61216e8b10b689", lines[4])
+		a.Equal("round[ 1].ik_add   e9f74eec023020f61bf2ccf2353c21c7", lines[6])
+		a.Equal("round[10].ioutput  00112233445566778899aabbccddeeff", lines[len(lines)-1])
+	}
+
+	_, err := WithTracer(nil, tr)
+	a.Error(err)
+}
+
+func TestPrinterAppendixB(t *testing.T) {
+	a := require.New(t)
+	b, err := NewCipher(unhex("2b7e151628aed2a6abf7158809cf4f3c"))
+	a.NoError(err)
+	var buf bytes.Buffer
+	p := trace.NewPrinter(&buf)
+	c, err := WithTracer(b, p)
+	a.NoError(err)
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, unhex("3243f6a8885a308d313198a2e0370734"))
+	a.NoError(p.Flush())
+	a.Equal("3925841d02dc09fbdc118597196a0b32", hex.EncodeToString(dst))
+
+	rounds := strings.Split(buf.String(), "\n\n")
+	a.Len(rounds, 12)
+	want := []string{
+		"round  start        s_box        s_row        m_col        k_sch",
+		"    1  19 a0 9a e9  d4 e0 b8 1e  d4 e0 b8 1e  04 e0 48 28  a0 88 23 2a",
+		"       3d f4 c6 f8  27 bf b4 41  bf b4 41 27  66 cb f8 06  fa 54 a3 6c",
+		"       e3 e2 8d 48  11 98 5d 52  5d 52 11 98  81 19 d3 26  fe 2c 39 76",
+		"       be 2b 2a 08  ae f1 e5 30  30 ae f1 e5  e5 9a 7a 4c  17 b1 39 05",
+	}
+	a.Equal(strings.Join(want, "\n"), rounds[1])
+}
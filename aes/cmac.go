@@ -0,0 +1,100 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"hash"
+)
+
+// cmac is CMAC from SP 800-38B and RFC 4493.
+type cmac struct {
+	b      cipher.Block
+	k1, k2 [BlockSize]byte
+	// x is the chaining value and buf the pending block, which is held
+	// back until more input shows it is not the last one.
+	x   [BlockSize]byte
+	buf [BlockSize]byte
+	n   int
+}
+
+// NewCMAC returns a CMAC keyed by b. b must be a 16-byte block cipher such as
+// one returned by NewCipher.
+func NewCMAC(b cipher.Block) (hash.Hash, error) {
+	if b.BlockSize() != BlockSize {
+		return nil, errors.New("aes: CMAC requires a 16-byte block cipher")
+	}
+	m := &cmac{b: b}
+	var l [BlockSize]byte
+	b.Encrypt(l[:], l[:])
+	m.k1 = dbl(l)
+	m.k2 = dbl(m.k1)
+	return m, nil
+}
+
+// dbl multiplies x by the generator of GF(2^128) with the polynomial
+// x^128 + x^7 + x^2 + x + 1, reading the block as a big-endian number.
+func dbl(x [BlockSize]byte) [BlockSize]byte {
+	var r [BlockSize]byte
+	for i := 0; i < BlockSize-1; i++ {
+		r[i] = x[i]<<1 | x[i+1]>>7
+	}
+	r[BlockSize-1] = x[BlockSize-1]<<1 ^ 0x87&-(x[0]>>7)
+	return r
+}
+
+func (m *cmac) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if m.n == BlockSize {
+			for i := range m.x {
+				m.x[i] ^= m.buf[i]
+			}
+			m.b.Encrypt(m.x[:], m.x[:])
+			m.n = 0
+		}
+		k := copy(m.buf[m.n:], p)
+		m.n += k
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (m *cmac) Sum(in []byte) []byte {
+	x := m.x
+	k := &m.k1
+	if m.n < BlockSize {
+		k = &m.k2
+	}
+	for i := range x {
+		var b byte
+		switch {
+		case i < m.n:
+			b = m.buf[i]
+		case i == m.n:
+			b = 0x80
+		}
+		x[i] ^= b ^ k[i]
+	}
+	m.b.Encrypt(x[:], x[:])
+	return append(in, x[:]...)
+}
+
+// sum writes the CMAC of data to dst, resetting m first.
+func (m *cmac) sum(dst, data []byte) {
+	m.Reset()
+	m.Write(data)
+	m.Sum(dst[:0])
+}
+
+func (m *cmac) Reset() {
+	m.x = [BlockSize]byte{}
+	m.n = 0
+}
+
+func (m *cmac) Size() int {
+	return BlockSize
+}
+
+func (m *cmac) BlockSize() int {
+	return BlockSize
+}
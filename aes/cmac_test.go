@@ -0,0 +1,78 @@
+package aes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCMACSubkeys(t *testing.T) {
+	a := require.New(t)
+	b, err := NewCipher(unhex("2b7e151628aed2a6abf7158809cf4f3c"))
+	a.NoError(err)
+	h, err := NewCMAC(b)
+	a.NoError(err)
+	m := h.(*cmac)
+	a.Equal(unhex("fbeed618357133667c85e08f7236a8de"), m.k1[:])
+	a.Equal(unhex("f7ddac306ae266ccf90bc11ee46d513b"), m.k2[:])
+}
+
+// RFC 4493 section 4 for AES-128 and SP 800-38B Appendix D for the others.
+func TestCMACVectors(t *testing.T) {
+	a := require.New(t)
+	msg := unhex("6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		key  string
+		macs [4]string
+	}{
+		{
+			"2b7e151628aed2a6abf7158809cf4f3c",
+			[4]string{
+				"bb1d6929e95937287fa37d129b756746",
+				"070a16b46b4d4144f79bdd9dd04a287c",
+				"dfa66747de9ae63030ca32611497c827",
+				"51f0bebf7e3b9d92fc49741779363cfe",
+			},
+		},
+		{
+			"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+			[4]string{
+				"d17ddf46adaacde531cac483de7a9367",
+				"9e99a7bf31e710900662f65e617c5184",
+				"8a1de5be2eb31aad089a82e6ee908b0e",
+				"a1d5df0eed790f794d77589659f39a11",
+			},
+		},
+		{
+			"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+			[4]string{
+				"028962f61b7bf89efc6b551f4667d983",
+				"28a7023f452e8f82bd4bf28d8c37c35c",
+				"aaf3d8f1de5640c232f5b169b9c911e6",
+				"e1992190549f6ed5696a2c056c315410",
+			},
+		},
+	}
+	for _, test := range tests {
+		b, err := NewCipher(unhex(test.key))
+		a.NoError(err)
+		h, err := NewCMAC(b)
+		a.NoError(err)
+		for i, n := range []int{0, 16, 40, 64} {
+			h.Reset()
+			h.Write(msg[:n])
+			a.Equal(unhex(test.macs[i]), h.Sum(nil))
+
+			// Byte-at-a-time writes and repeated Sums agree.
+			h.Reset()
+			for j := 0; j < n; j++ {
+				h.Write(msg[j : j+1])
+				h.Sum(nil)
+			}
+			a.Equal(unhex(test.macs[i]), h.Sum([]byte{})[:BlockSize])
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+)
+
+// CTR is counter mode over a 16-byte block cipher. The counter is a
+// big-endian integer in bytes [offset, offset+size) of the counter block and
+// wraps modulo 2^(8*size); the other bytes stay fixed as a nonce. CTR
+// implements cipher.Stream and can seek to any byte of the keystream.
+type CTR struct {
+	b      cipher.Block
+	iv     [BlockSize]byte
+	lo, hi int
+	// ks holds the keystream of the blocks before next, of which used bytes
+	// have been consumed.
+	ks   [bsBlocks * BlockSize]byte
+	next uint64
+	used int
+}
+
+var _ cipher.Stream = (*CTR)(nil)
+
+// NewCTR returns counter mode with the whole of iv as the counter, as in
+// crypto/cipher.NewCTR.
+func NewCTR(b cipher.Block, iv []byte) (*CTR, error) {
+	return NewCTRWithCounter(b, iv, 0, BlockSize)
+}
+
+// NewCTRWithCounter returns counter mode with the counter in the size bytes
+// of iv starting at offset. SP 800-38A's common choice of a 64-bit nonce
+// followed by a 64-bit counter is offset 8, size 8.
+func NewCTRWithCounter(b cipher.Block, iv []byte, offset, size int) (*CTR, error) {
+	if b.BlockSize() != BlockSize {
+		return nil, errors.New("aes: CTR requires a 16-byte block cipher")
+	}
+	if len(iv) != BlockSize {
+		return nil, errors.New("aes: IV length must equal block size")
+	}
+	if offset < 0 || size < 1 || offset+size > BlockSize {
+		return nil, errors.New("aes: invalid counter field")
+	}
+	c := &CTR{b: b, lo: offset, hi: offset + size}
+	copy(c.iv[:], iv)
+	c.used = len(c.ks)
+	return c, nil
+}
+
+func (c *CTR) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("aes: output smaller than input")
+	}
+	for len(src) > 0 {
+		if c.used == len(c.ks) {
+			c.refill()
+		}
+		n := len(src)
+		if n > len(c.ks)-c.used {
+			n = len(c.ks) - c.used
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ c.ks[c.used+i]
+		}
+		c.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// refill encrypts the counter blocks for the next len(ks)/BlockSize blocks.
+func (c *CTR) refill() {
+	for i := 0; i < len(c.ks); i += BlockSize {
+		c.counter(c.ks[i:i+BlockSize], c.next)
+		c.next++
+	}
+	if m, ok := c.b.(MultiBlock); ok {
+		m.EncryptBlocks(c.ks[:], c.ks[:])
+	} else {
+		for i := 0; i < len(c.ks); i += BlockSize {
+			c.b.Encrypt(c.ks[i:], c.ks[i:])
+		}
+	}
+	c.used = 0
+}
+
+// counter writes the counter block for block number n into dst.
+func (c *CTR) counter(dst []byte, n uint64) {
+	copy(dst, c.iv[:])
+	for i := c.hi - 1; i >= c.lo && n != 0; i-- {
+		s := uint64(dst[i]) + n&0xff
+		dst[i] = byte(s)
+		n = n>>8 + s>>8
+	}
+}
+
+// Seek moves to a byte offset in the keystream. io.SeekEnd is not supported
+// since the keystream has no end.
+func (c *CTR) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset()
+	default:
+		return 0, errors.New("aes: CTR.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("aes: CTR.Seek: negative position")
+	}
+	n := offset / int64(len(c.ks))
+	c.next = uint64(n) * uint64(len(c.ks)/BlockSize)
+	c.refill()
+	c.used = int(offset - n*int64(len(c.ks)))
+	return offset, nil
+}
+
+func (c *CTR) offset() int64 {
+	return int64(c.next)*BlockSize - int64(len(c.ks)-c.used)
+}
+
+// StreamReader applies S to everything read from R. It seeks S along with R
+// if R is an io.Seeker.
+type StreamReader struct {
+	S *CTR
+	R io.Reader
+}
+
+func (r StreamReader) Read(p []byte) (int, error) {
+	n, err := r.R.Read(p)
+	r.S.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+func (r StreamReader) Seek(offset int64, whence int) (int64, error) {
+	s, ok := r.R.(io.Seeker)
+	if !ok {
+		return 0, errors.New("aes: StreamReader: underlying reader cannot seek")
+	}
+	pos, err := s.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	return r.S.Seek(pos, io.SeekStart)
+}
+
+// StreamWriter applies S to everything written to W. Close closes W if it is
+// an io.Closer.
+type StreamWriter struct {
+	S   *CTR
+	W   io.Writer
+	buf []byte
+}
+
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+	buf := w.buf[:len(p)]
+	w.S.XORKeyStream(buf, p)
+	n, err := w.W.Write(buf)
+	if n != len(p) && err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+func (w *StreamWriter) Close() error {
+	if c, ok := w.W.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// SP 800-38A F.5.1, F.5.3 and F.5.5.
+func TestCTRVectors(t *testing.T) {
+	a := require.New(t)
+	iv := unhex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	pt := unhex("6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		key, ct string
+	}{
+		{
+			"2b7e151628aed2a6abf7158809cf4f3c",
+			"874d6191b620e3261bef6864990db6ce" +
+				"9806f66b7970fdff8617187bb9fffdff" +
+				"5ae4df3edbd5d35e5b4f09020db03eab" +
+				"1e031dda2fbe03d1792170a0f3009cee",
+		},
+		{
+			"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b",
+			"1abc932417521ca24f2b0459fe7e6e0b" +
+				"090339ec0aa6faefd5ccc2c6f4ce8e94" +
+				"1e36b26bd1ebc670d1bd1d665620abf7" +
+				"4f78a7f6d29809585a97daec58c6b050",
+		},
+		{
+			"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4",
+			"601ec313775789a5b7a7f504bbf3d228" +
+				"f443e3ca4d62b59aca84e990cacaf5c5" +
+				"2b0930daa23de94ce87017ba2d84988d" +
+				"dfc9c58db67aada613c2dd08457941a6",
+		},
+	}
+	for _, test := range tests {
+		for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+			b, err := NewCipherWithBackend(unhex(test.key), backend)
+			a.NoError(err)
+			c, err := NewCTR(b, iv)
+			a.NoError(err)
+			dst := make([]byte, len(pt))
+			c.XORKeyStream(dst, pt)
+			a.Equal(unhex(test.ct), dst)
+
+			// Decrypt in uneven pieces.
+			c, err = NewCTR(b, iv)
+			a.NoError(err)
+			for i := 0; i < len(dst); i += 7 {
+				j := i + 7
+				if j > len(dst) {
+					j = len(dst)
+				}
+				c.XORKeyStream(dst[i:j], dst[i:j])
+			}
+			a.Equal(pt, dst)
+		}
+	}
+}
+
+func TestCTRCounterField(t *testing.T) {
+	a := require.New(t)
+	b, err := NewCipher(make([]byte, 16))
+	a.NoError(err)
+
+	// A 32-bit counter in the last four bytes wraps without carrying into
+	// the nonce.
+	iv := unhex("000102030405060708090a0bfffffffe")
+	c, err := NewCTRWithCounter(b, iv, 12, 4)
+	a.NoError(err)
+	ks := make([]byte, 3*BlockSize)
+	c.XORKeyStream(ks, ks)
+	for i, ctr := range []string{
+		"000102030405060708090a0bfffffffe",
+		"000102030405060708090a0bffffffff",
+		"000102030405060708090a0b00000000",
+	} {
+		want := make([]byte, BlockSize)
+		b.Encrypt(want, unhex(ctr))
+		a.Equal(want, ks[BlockSize*i:BlockSize*(i+1)])
+	}
+
+	// A counter in the middle leaves the bytes after it alone.
+	iv = unhex("0001020304050607ffff0a0b0c0d0e0f")
+	c, err = NewCTRWithCounter(b, iv, 6, 4)
+	a.NoError(err)
+	c.XORKeyStream(ks, make([]byte, len(ks)))
+	want := make([]byte, BlockSize)
+	b.Encrypt(want, unhex("00010203040506080000"+"0a0b0c0d0e0f"))
+	a.Equal(want, ks[BlockSize:2*BlockSize])
+
+	for _, f := range [][2]int{{-1, 4}, {0, 0}, {12, 5}, {0, 17}} {
+		_, err = NewCTRWithCounter(b, iv, f[0], f[1])
+		a.Error(err)
+	}
+	_, err = NewCTR(b, iv[:8])
+	a.Error(err)
+}
+
+func TestCTRStdlib(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	iv := make([]byte, BlockSize)
+	src := make([]byte, 1000)
+	rg.Read(key)
+	rg.Read(iv)
+	rg.Read(src)
+	iv[15] = 0xf0
+	b, err := NewCipher(key)
+	a.NoError(err)
+	want := make([]byte, len(src))
+	cipher.NewCTR(b, iv).XORKeyStream(want, src)
+	c, err := NewCTR(b, iv)
+	a.NoError(err)
+	dst := make([]byte, len(src))
+	c.XORKeyStream(dst, src)
+	a.Equal(want, dst)
+}
+
+func TestCTRSeek(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	b, err := NewCipherWithBackend(make([]byte, 16), TTable)
+	a.NoError(err)
+	iv := make([]byte, BlockSize)
+	c, err := NewCTR(b, iv)
+	a.NoError(err)
+	ks := make([]byte, 4096)
+	c.XORKeyStream(ks, ks)
+
+	for i := 0; i < 100; i++ {
+		off := rg.Intn(len(ks))
+		n := rg.Intn(len(ks) - off)
+		pos, err := c.Seek(int64(off), io.SeekStart)
+		a.NoError(err)
+		a.Equal(int64(off), pos)
+		buf := make([]byte, n)
+		c.XORKeyStream(buf, buf)
+		a.Equal(ks[off:off+n], buf)
+		pos, err = c.Seek(0, io.SeekCurrent)
+		a.NoError(err)
+		a.Equal(int64(off+n), pos)
+	}
+
+	_, err = c.Seek(-1, io.SeekStart)
+	a.Error(err)
+	_, err = c.Seek(0, io.SeekEnd)
+	a.Error(err)
+}
+
+func TestCTRStream(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	b, err := NewCipherWithBackend(make([]byte, 32), Bitsliced)
+	a.NoError(err)
+	iv := make([]byte, BlockSize)
+	src := make([]byte, 3000)
+	rg.Read(src)
+
+	var ct bytes.Buffer
+	c, err := NewCTR(b, iv)
+	a.NoError(err)
+	w := &StreamWriter{S: c, W: &ct}
+	for p := src; len(p) > 0; {
+		n := 1 + rg.Intn(100)
+		if n > len(p) {
+			n = len(p)
+		}
+		_, err := w.Write(p[:n])
+		a.NoError(err)
+		p = p[n:]
+	}
+	a.NoError(w.Close())
+
+	c, err = NewCTR(b, iv)
+	a.NoError(err)
+	r := StreamReader{S: c, R: bytes.NewReader(ct.Bytes())}
+	got, err := io.ReadAll(r)
+	a.NoError(err)
+	a.Equal(src, got)
+
+	_, err = r.Seek(1234, io.SeekStart)
+	a.NoError(err)
+	got, err = io.ReadAll(r)
+	a.NoError(err)
+	a.Equal(src[1234:], got)
+
+	_, err = StreamReader{S: c, R: &ct}.Seek(0, io.SeekStart)
+	a.Error(err)
+}
+
+func BenchmarkCTR(b *testing.B) {
+	for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+		block, err := NewCipherWithBackend(make([]byte, 16), backend)
+		if err != nil {
+			b.Fatal(err)
+		}
+		c, err := NewCTR(block, make([]byte, BlockSize))
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf := make([]byte, 4096)
+		b.Run([]string{"Reference", "TTable", "Bitsliced"}[backend], func(b *testing.B) {
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				c.XORKeyStream(buf, buf)
+			}
+		})
+	}
+}
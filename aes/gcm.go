@@ -0,0 +1,228 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmStandardNonceSize = 12
+	gcmTagSize           = 16
+)
+
+var errOpen = errors.New("aes: message authentication failed")
+
+// gcmHash is GHASH with Shoup's 4-bit tables. Field elements are held as two
+// big-endian halves, so bit 0 of the GCM polynomial is the top bit of the
+// first half and multiplying by x is a right shift. The table lookups depend
+// on the data hashed, so unlike the Bitsliced block backend this is not
+// constant time.
+type gcmHash struct {
+	// hh and hl hold the halves of n*H for each 4-bit n, whose top bit is
+	// the coefficient of x^0.
+	hh, hl [16]uint64
+}
+
+// gcmReduce is the reduction of the four bits shifted out by a multiply by
+// x^4, placed at the top of the first half.
+var gcmReduce = [16]uint64{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+func newGCMHash(h []byte) *gcmHash {
+	g := &gcmHash{}
+	vh := binary.BigEndian.Uint64(h[:8])
+	vl := binary.BigEndian.Uint64(h[8:])
+	g.hh[8], g.hl[8] = vh, vl
+	for i := 4; i > 0; i >>= 1 {
+		r := -(vl & 1) & (0xe1 << 56)
+		vl = vh<<63 | vl>>1
+		vh = vh>>1 ^ r
+		g.hh[i], g.hl[i] = vh, vl
+	}
+	for i := 2; i <= 8; i *= 2 {
+		for j := 1; j < i; j++ {
+			g.hh[i+j] = g.hh[i] ^ g.hh[j]
+			g.hl[i+j] = g.hl[i] ^ g.hl[j]
+		}
+	}
+	return g
+}
+
+// mul returns y*H, consuming y a nibble at a time from its last byte.
+func (g *gcmHash) mul(y0, y1 uint64) (uint64, uint64) {
+	var zh, zl uint64
+	for i := 15; i >= 0; i-- {
+		var b byte
+		if i < 8 {
+			b = byte(y0 >> (56 - 8*i))
+		} else {
+			b = byte(y1 >> (56 - 8*(i-8)))
+		}
+		for k, n := range [2]byte{b & 0xf, b >> 4} {
+			if i != 15 || k != 0 {
+				r := zl & 0xf
+				zl = zh<<60 | zl>>4
+				zh = zh>>4 ^ gcmReduce[r]<<48
+			}
+			zh ^= g.hh[n]
+			zl ^= g.hl[n]
+		}
+	}
+	return zh, zl
+}
+
+// update absorbs data into y, zero-padding the last block.
+func (g *gcmHash) update(y *[2]uint64, data []byte) {
+	for len(data) > 0 {
+		var b [BlockSize]byte
+		n := copy(b[:], data)
+		data = data[n:]
+		y[0] ^= binary.BigEndian.Uint64(b[:8])
+		y[1] ^= binary.BigEndian.Uint64(b[8:])
+		y[0], y[1] = g.mul(y[0], y[1])
+	}
+}
+
+type gcm struct {
+	b         cipher.Block
+	h         *gcmHash
+	nonceSize int
+	tagSize   int
+}
+
+// NewGCM returns b in Galois/Counter Mode with a 12-byte nonce and a 16-byte
+// tag. b must be a 16-byte block cipher such as one returned by NewCipher.
+func NewGCM(b cipher.Block) (cipher.AEAD, error) {
+	return newGCM(b, gcmStandardNonceSize, gcmTagSize)
+}
+
+// NewGCMWithNonceSize is NewGCM with a nonce of size bytes. Nonces other than
+// 12 bytes are hashed into the initial counter block.
+func NewGCMWithNonceSize(b cipher.Block, size int) (cipher.AEAD, error) {
+	return newGCM(b, size, gcmTagSize)
+}
+
+// NewGCMWithTagSize is NewGCM with the tag truncated to tagSize bytes, which
+// must be 4, 8 or 12 to 16 as in SP 800-38D.
+func NewGCMWithTagSize(b cipher.Block, tagSize int) (cipher.AEAD, error) {
+	return newGCM(b, gcmStandardNonceSize, tagSize)
+}
+
+func newGCM(b cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if b.BlockSize() != BlockSize {
+		return nil, errors.New("aes: GCM requires a 16-byte block cipher")
+	}
+	if nonceSize <= 0 {
+		return nil, errors.New("aes: GCM nonce size must be positive")
+	}
+	if tagSize != 4 && tagSize != 8 && (tagSize < 12 || tagSize > gcmTagSize) {
+		return nil, errors.New("aes: invalid GCM tag size")
+	}
+	var h [BlockSize]byte
+	b.Encrypt(h[:], h[:])
+	return &gcm{b: b, h: newGCMHash(h[:]), nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+func (g *gcm) NonceSize() int {
+	return g.nonceSize
+}
+
+func (g *gcm) Overhead() int {
+	return g.tagSize
+}
+
+func (g *gcm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != g.nonceSize {
+		panic("aes: incorrect nonce length given to GCM")
+	}
+	if uint64(len(plaintext)) > (1<<32-2)*BlockSize {
+		panic("aes: message too large for GCM")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+g.tagSize)
+	ctr := g.counter(nonce)
+	var mask [BlockSize]byte
+	ctr.XORKeyStream(mask[:], mask[:])
+	ctr.XORKeyStream(out, plaintext)
+
+	tag := g.tag(mask[:], additionalData, out[:len(plaintext)])
+	copy(out[len(plaintext):], tag[:g.tagSize])
+	return ret
+}
+
+func (g *gcm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != g.nonceSize {
+		panic("aes: incorrect nonce length given to GCM")
+	}
+	if len(ciphertext) < g.tagSize || uint64(len(ciphertext)) > (1<<32-2)*BlockSize+uint64(g.tagSize) {
+		return nil, errOpen
+	}
+	tag := ciphertext[len(ciphertext)-g.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-g.tagSize]
+
+	ctr := g.counter(nonce)
+	var mask [BlockSize]byte
+	ctr.XORKeyStream(mask[:], mask[:])
+	want := g.tag(mask[:], additionalData, ciphertext)
+	if subtle.ConstantTimeCompare(want[:g.tagSize], tag) != 1 {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	ctr.XORKeyStream(out, ciphertext)
+	return ret, nil
+}
+
+// counter returns the keystream starting at J0, whose first block masks the
+// tag and whose remainder encrypts the message. Only the low 32 bits count.
+func (g *gcm) counter(nonce []byte) *CTR {
+	var j0 [BlockSize]byte
+	if len(nonce) == gcmStandardNonceSize {
+		copy(j0[:], nonce)
+		j0[BlockSize-1] = 1
+	} else {
+		var y [2]uint64
+		g.h.update(&y, nonce)
+		y[1] ^= uint64(len(nonce)) * 8
+		y[0], y[1] = g.h.mul(y[0], y[1])
+		binary.BigEndian.PutUint64(j0[:8], y[0])
+		binary.BigEndian.PutUint64(j0[8:], y[1])
+	}
+	ctr, err := NewCTRWithCounter(g.b, j0[:], 12, 4)
+	if err != nil {
+		panic(err)
+	}
+	return ctr
+}
+
+func (g *gcm) tag(mask, additionalData, ciphertext []byte) [BlockSize]byte {
+	var y [2]uint64
+	g.h.update(&y, additionalData)
+	g.h.update(&y, ciphertext)
+	y[0] ^= uint64(len(additionalData)) * 8
+	y[1] ^= uint64(len(ciphertext)) * 8
+	y[0], y[1] = g.h.mul(y[0], y[1])
+
+	var t [BlockSize]byte
+	binary.BigEndian.PutUint64(t[:8], y[0])
+	binary.BigEndian.PutUint64(t[8:], y[1])
+	for i := range t {
+		t[i] ^= mask[i]
+	}
+	return t
+}
+
+// sliceForAppend extends in by n bytes and returns the whole slice and the
+// new tail.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
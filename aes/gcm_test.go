@@ -0,0 +1,184 @@
+package aes
+
+import (
+	"bytes"
+	stdaes "crypto/aes"
+	"crypto/cipher"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test cases 1 to 18 of McGrew and Viega, "The Galois/Counter Mode of
+// Operation (GCM)". ct holds the ciphertext followed by the tag.
+var gcmVectors = func() []struct{ key, pt, iv, ad, ct string } {
+	p64 := "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255"
+	p60 := p64[:120]
+	ad := "feedfacedeadbeeffeedfacedeadbeefabaddad2"
+	k := "feffe9928665731c6d6a8f9467308308"
+	iv := "cafebabefacedbaddecaf888"
+	iv8 := "cafebabefacedbad"
+	iv60 := "9313225df88406e555909c5aff5269aa6a7a9538534f7da1e4c303d2a318a728" +
+		"c3c0c95156809539fcf0e2429a6b525416aedbf5a0de6a57a637b39b"
+	z16 := "00000000000000000000000000000000"
+	z12 := "000000000000000000000000"
+	return []struct{ key, pt, iv, ad, ct string }{
+		{z16, "", z12, "", "58e2fccefa7e3061367f1d57a4e7455a"},
+		{z16, z16, z12, "", "0388dace60b6a392f328c2b971b2fe78ab6e47d42cec13bdf53a67b21257bddf"},
+		{k, p64, iv, "", "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985" +
+			"4d5c2af327cd64a62cf35abd2ba6fab4"},
+		{k, p60, iv, ad, "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+			"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091" +
+			"5bc94fbc3221a5db94fae95ae7121a47"},
+		{k, p60, iv8, ad, "61353b4c2806934a777ff51fa22a4755699b2a714fcdc6f83766e5f97b6c7423" +
+			"73806900e49f24b22b097544d4896b424989b5e1ebac0f07c23f4598" +
+			"3612d2e79e3b0785561be14aaca2fccb"},
+		{k, p60, iv60, ad, "8ce24998625615b603a033aca13fb894be9112a5c3a211a8ba262a3cca7e2ca7" +
+			"01e4a9a4fba43c90ccdcb281d48c7c6fd62875d2aca417034c34aee5" +
+			"619cc5aefffe0bfa462af43c1699d050"},
+		{z16 + z16[:16], "", z12, "", "cd33b28ac773f74ba00ed1f312572435"},
+		{z16 + z16[:16], z16, z12, "", "98e7247c07f0fe411c267e4384b0f6002ff58d80033927ab8ef4d4587514f0fb"},
+		{k + k[:16], p64, iv, "", "3980ca0b3c00e841eb06fac4872a2757859e1ceaa6efd984628593b40ca1e19c" +
+			"7d773d00c144c525ac619d18c84a3f4718e2448b2fe324d9ccda2710acade256" +
+			"9924a7c8587336bfb118024db8674a14"},
+		{k + k[:16], p60, iv, ad, "3980ca0b3c00e841eb06fac4872a2757859e1ceaa6efd984628593b40ca1e19c" +
+			"7d773d00c144c525ac619d18c84a3f4718e2448b2fe324d9ccda2710" +
+			"2519498e80f1478f37ba55bd6d27618c"},
+		{k + k[:16], p60, iv8, ad, "0f10f599ae14a154ed24b36e25324db8c566632ef2bbb34f8347280fc4507057" +
+			"fddc29df9a471f75c66541d4d4dad1c9e93a19a58e8b473fa0f062f7" +
+			"65dcc57fcf623a24094fcca40d3533f8"},
+		{k + k[:16], p60, iv60, ad, "d27e88681ce3243c4830165a8fdcf9ff1de9a1d8e6b447ef6ef7b79828666e45" +
+			"81e79012af34ddd9e2f037589b292db3e67c036745fa22e7e9b7373b" +
+			"dcf566ff291c25bbb8568fc3d376a6d9"},
+		{z16 + z16, "", z12, "", "530f8afbc74536b9a963b4f1c4cb738b"},
+		{z16 + z16, z16, z12, "", "cea7403d4d606b6e074ec5d3baf39d18d0d1c8a799996bf0265b98b5d48ab919"},
+		{k + k, p64, iv, "", "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+			"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662898015ad" +
+			"b094dac5d93471bdec1a502270e3cc6c"},
+		{k + k, p60, iv, ad, "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+			"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662" +
+			"76fc6ece0f4e1768cddf8853bb2d551b"},
+		{k + k, p60, iv8, ad, "c3762df1ca787d32ae47c13bf19844cbaf1ae14d0b976afac52ff7d79bba9de0" +
+			"feb582d33934a4f0954cc2363bc73f7862ac430e64abe499f47c9b1f" +
+			"3a337dbf46a792c45e454913fe2ea8f2"},
+		{k + k, p60, iv60, ad, "5a8def2f0c9e53f1f75d7853659e2a20eeb2b22aafde6419a058ab4f6f746bf4" +
+			"0fc0c3b780f244452da3ebf1c5d82cdea2418997200ef82e44ae7e3f" +
+			"a44a8266ee1c8eb0c8b5d4cf5ae9f19a"},
+	}
+}()
+
+func TestGCMVectors(t *testing.T) {
+	a := require.New(t)
+	for i, test := range gcmVectors {
+		for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+			b, err := NewCipherWithBackend(unhex(test.key), backend)
+			a.NoError(err)
+			g, err := NewGCMWithNonceSize(b, len(test.iv)/2)
+			a.NoError(err)
+			ct := g.Seal(nil, unhex(test.iv), unhex(test.pt), unhex(test.ad))
+			a.Equal(unhex(test.ct), ct, "test case %d", i+1)
+			pt, err := g.Open(nil, unhex(test.iv), ct, unhex(test.ad))
+			a.NoError(err)
+			a.True(bytes.Equal(unhex(test.pt), pt))
+
+			ct[len(ct)-1] ^= 1
+			_, err = g.Open(nil, unhex(test.iv), ct, unhex(test.ad))
+			a.Error(err)
+		}
+	}
+}
+
+func TestGCMStdlib(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < 200; i++ {
+		key := make([]byte, 16+8*(i%3))
+		rg.Read(key)
+		b, err := NewCipher(key)
+		a.NoError(err)
+		std, err := stdaes.NewCipher(key)
+		a.NoError(err)
+
+		var g, sg cipher.AEAD
+		switch i % 2 {
+		case 0:
+			size := []int{1, 8, 12, 16, 60}[rg.Intn(5)]
+			g, err = NewGCMWithNonceSize(b, size)
+			a.NoError(err)
+			sg, err = cipher.NewGCMWithNonceSize(std, size)
+			a.NoError(err)
+		case 1:
+			size := 12 + rg.Intn(5)
+			g, err = NewGCMWithTagSize(b, size)
+			a.NoError(err)
+			sg, err = cipher.NewGCMWithTagSize(std, size)
+			a.NoError(err)
+		}
+		a.Equal(sg.NonceSize(), g.NonceSize())
+		a.Equal(sg.Overhead(), g.Overhead())
+
+		nonce := make([]byte, g.NonceSize())
+		pt := make([]byte, rg.Intn(200))
+		ad := make([]byte, rg.Intn(40))
+		rg.Read(nonce)
+		rg.Read(pt)
+		rg.Read(ad)
+		prefix := []byte("prefix")
+		ct := g.Seal(prefix, nonce, pt, ad)
+		a.Equal(sg.Seal(prefix, nonce, pt, ad), ct)
+
+		got, err := sg.Open(nil, nonce, ct[len(prefix):], ad)
+		a.NoError(err)
+		a.True(bytes.Equal(pt, got))
+		got, err = g.Open(prefix, nonce, ct[len(prefix):], ad)
+		a.NoError(err)
+		a.Equal(append(prefix, pt...), got)
+	}
+}
+
+func TestGCMTagSize(t *testing.T) {
+	a := require.New(t)
+	b, err := NewCipher(make([]byte, 16))
+	a.NoError(err)
+	full, err := NewGCM(b)
+	a.NoError(err)
+	nonce := make([]byte, 12)
+	pt := []byte("truncated tags are prefixes")
+	want := full.Seal(nil, nonce, pt, nil)
+	for _, size := range []int{4, 8, 12, 13, 14, 15, 16} {
+		g, err := NewGCMWithTagSize(b, size)
+		a.NoError(err)
+		ct := g.Seal(nil, nonce, pt, nil)
+		a.Equal(want[:len(pt)+size], ct)
+		_, err = g.Open(nil, nonce, ct[:len(ct)-1], nil)
+		a.Error(err)
+	}
+	for _, size := range []int{0, 3, 5, 11, 17} {
+		_, err := NewGCMWithTagSize(b, size)
+		a.Error(err)
+	}
+	_, err = NewGCMWithNonceSize(b, 0)
+	a.Error(err)
+	a.Panics(func() { full.Seal(nil, nonce[:8], pt, nil) })
+}
+
+func BenchmarkGCM(b *testing.B) {
+	block, err := NewCipherWithBackend(make([]byte, 16), TTable)
+	if err != nil {
+		b.Fatal(err)
+	}
+	g, err := NewGCM(block)
+	if err != nil {
+		b.Fatal(err)
+	}
+	nonce := make([]byte, g.NonceSize())
+	buf := make([]byte, 4096, 4096+g.Overhead())
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		g.Seal(buf[:0], nonce, buf, nil)
+	}
+}
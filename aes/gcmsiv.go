@@ -0,0 +1,172 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+// polyval is POLYVAL from RFC 8452, computed with GHASH through the identity
+// of its Appendix A: POLYVAL(H, X) = rev(GHASH(mulX(rev(H)), rev(X))), where
+// rev reverses the bytes of a block.
+type polyval struct {
+	h *gcmHash
+	y [2]uint64
+}
+
+func newPolyval(key []byte) *polyval {
+	var h [BlockSize]byte
+	for i := range h {
+		h[i] = key[BlockSize-1-i]
+	}
+	// Multiply by x in GHASH's bit order.
+	carry := h[BlockSize-1] & 1
+	for i := BlockSize - 1; i > 0; i-- {
+		h[i] = h[i]>>1 | h[i-1]<<7
+	}
+	h[0] = h[0]>>1 ^ 0xe1&-carry
+	return &polyval{h: newGCMHash(h[:])}
+}
+
+// update absorbs data, zero-padding the last block.
+func (p *polyval) update(data []byte) {
+	for len(data) > 0 {
+		var b, r [BlockSize]byte
+		n := copy(b[:], data)
+		data = data[n:]
+		for i := range r {
+			r[i] = b[BlockSize-1-i]
+		}
+		p.h.update(&p.y, r[:])
+	}
+}
+
+func (p *polyval) sum() [BlockSize]byte {
+	var s [BlockSize]byte
+	binary.LittleEndian.PutUint64(s[8:], p.y[0])
+	binary.LittleEndian.PutUint64(s[:8], p.y[1])
+	return s
+}
+
+const gcmSIVMaxLen = 1 << 36
+
+type gcmSIV struct {
+	key []byte
+	b   cipher.Block
+}
+
+// NewGCMSIV returns AES-GCM-SIV from RFC 8452 with a 16- or 32-byte key. It
+// takes 12-byte nonces and adds a 16-byte tag. Every message derives its own
+// keys from the nonce.
+func NewGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 16 && len(key) != 32 {
+		return nil, KeySizeError(len(key))
+	}
+	b, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{key: append([]byte(nil), key...), b: b}, nil
+}
+
+func (g *gcmSIV) NonceSize() int {
+	return gcmStandardNonceSize
+}
+
+func (g *gcmSIV) Overhead() int {
+	return gcmTagSize
+}
+
+// deriveKeys returns the POLYVAL key and the block cipher for one nonce.
+func (g *gcmSIV) deriveKeys(nonce []byte) ([]byte, cipher.Block) {
+	keys := make([]byte, BlockSize+len(g.key))
+	var in, out [BlockSize]byte
+	copy(in[4:], nonce)
+	for i := 0; i < len(keys)/8; i++ {
+		binary.LittleEndian.PutUint32(in[:4], uint32(i))
+		g.b.Encrypt(out[:], in[:])
+		copy(keys[8*i:], out[:8])
+	}
+	b, err := NewCipher(keys[BlockSize:])
+	if err != nil {
+		panic(err)
+	}
+	return keys[:BlockSize], b
+}
+
+func (g *gcmSIV) tag(authKey []byte, b cipher.Block, nonce, plaintext, additionalData []byte) [BlockSize]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+	var lens [BlockSize]byte
+	binary.LittleEndian.PutUint64(lens[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lens[8:], uint64(len(plaintext))*8)
+	p.update(lens[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[BlockSize-1] &= 0x7f
+	b.Encrypt(s[:], s[:])
+	return s
+}
+
+// gcmSIVCrypt runs CTR from the tag with its top bit set, using a 32-bit
+// little-endian counter in the first four bytes.
+func gcmSIVCrypt(b cipher.Block, dst, src []byte, tag [BlockSize]byte) {
+	ctr := tag
+	ctr[BlockSize-1] |= 0x80
+	var ks [BlockSize]byte
+	for len(src) > 0 {
+		b.Encrypt(ks[:], ctr[:])
+		binary.LittleEndian.PutUint32(ctr[:4], binary.LittleEndian.Uint32(ctr[:4])+1)
+		n := len(src)
+		if n > BlockSize {
+			n = BlockSize
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ ks[i]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmStandardNonceSize {
+		panic("aes: incorrect nonce length given to GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLen || uint64(len(additionalData)) > gcmSIVMaxLen {
+		panic("aes: message too large for GCM-SIV")
+	}
+	authKey, b := g.deriveKeys(nonce)
+	tag := g.tag(authKey, b, nonce, plaintext, additionalData)
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmTagSize)
+	gcmSIVCrypt(b, out, plaintext, tag)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmStandardNonceSize {
+		panic("aes: incorrect nonce length given to GCM-SIV")
+	}
+	if len(ciphertext) < gcmTagSize || uint64(len(ciphertext)) > gcmSIVMaxLen+gcmTagSize ||
+		uint64(len(additionalData)) > gcmSIVMaxLen {
+		return nil, errOpen
+	}
+	var tag [BlockSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmTagSize]
+
+	authKey, b := g.deriveKeys(nonce)
+	pt := make([]byte, len(ciphertext))
+	gcmSIVCrypt(b, pt, ciphertext, tag)
+	want := g.tag(authKey, b, nonce, pt, additionalData)
+	if subtle.ConstantTimeCompare(want[:], tag[:]) != 1 {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(pt))
+	copy(out, pt)
+	return ret, nil
+}
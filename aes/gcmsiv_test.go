@@ -0,0 +1,90 @@
+package aes
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolyval(t *testing.T) {
+	a := require.New(t)
+	// RFC 8452 appendix A.
+	p := newPolyval(unhex("25629347589242761d31f826ba4b757b"))
+	p.update(unhex("4f4f95668c83dfb6401762bb2d01a262d1a24ddd2721d006bbe45f20d3c9f362"))
+	s := p.sum()
+	a.Equal(unhex("f7a3b47b846119fae5b7866cf5e5b77e"), s[:])
+}
+
+// RFC 8452 appendix C.1 and C.2.
+func TestGCMSIVVectors(t *testing.T) {
+	a := require.New(t)
+	nonce := unhex("030000000000000000000000")
+	k128 := "01000000000000000000000000000000"
+	k256 := k128 + "00000000000000000000000000000000"
+	tests := []struct {
+		key, pt, ad, ct string
+	}{
+		{k128, "", "", "dc20e2d83f25705bb49e439eca56de25"},
+		{k128, "0100000000000000", "", "b5d839330ac7b786578782fff6013b815b287c22493a364c"},
+		{k128, "010000000000000000000000", "", "7323ea61d05932260047d942a4978db357391a0bc4fdec8b0d106639"},
+		{k128, "01000000000000000000000000000000", "", "743f7c8077ab25f8624e2e948579cf77303aaf90f6fe21199c6068577437a0c4"},
+		{k128, "0100000000000000000000000000000002000000000000000000000000000000", "",
+			"84e07e62ba83a6585417245d7ec413a9fe427d6315c09b57ce45f2e3936a94451a8e45dcd4578c667cd86847bf6155ff"},
+		{k128, "0200000000000000", "01", "1e6daba35669f4273b0a1a2560969cdf790d99759abd1508"},
+		{k128, "020000000000000000000000", "01", "296c7889fd99f41917f4462008299c5102745aaa3a0c469fad9e075a"},
+		{k256, "", "", "07f5f4169bbf55a8400cd47ea6fd400f"},
+		{k256, "0100000000000000", "", "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28"},
+	}
+	for _, test := range tests {
+		g, err := NewGCMSIV(unhex(test.key))
+		a.NoError(err)
+		ct := g.Seal(nil, nonce, unhex(test.pt), unhex(test.ad))
+		a.Equal(unhex(test.ct), ct)
+		pt, err := g.Open(nil, nonce, ct, unhex(test.ad))
+		a.NoError(err)
+		a.True(bytes.Equal(unhex(test.pt), pt))
+	}
+}
+
+func TestGCMSIVKeys(t *testing.T) {
+	a := require.New(t)
+	g, err := NewGCMSIV(unhex("01000000000000000000000000000000"))
+	a.NoError(err)
+	authKey, b := g.(*gcmSIV).deriveKeys(unhex("030000000000000000000000"))
+	a.Equal(unhex("d9b360279694941ac5dbc6987ada7377"), authKey)
+	want, err := NewCipher(unhex("4004a0dcd862f2a57360219d2d44ef6c"))
+	a.NoError(err)
+	a.Equal(want, b)
+}
+
+func TestGCMSIV(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, size := range []int{16, 32} {
+		key := make([]byte, size)
+		rg.Read(key)
+		g, err := NewGCMSIV(key)
+		a.NoError(err)
+		for n := 0; n < 70; n++ {
+			nonce := make([]byte, g.NonceSize())
+			pt := make([]byte, n)
+			ad := make([]byte, rg.Intn(40))
+			rg.Read(nonce)
+			rg.Read(pt)
+			rg.Read(ad)
+			ct := g.Seal([]byte("x"), nonce, pt, ad)
+			got, err := g.Open(nil, nonce, ct[1:], ad)
+			a.NoError(err)
+			a.True(bytes.Equal(pt, got))
+
+			ct[1+rg.Intn(len(ct)-1)] ^= 1
+			_, err = g.Open(nil, nonce, ct[1:], ad)
+			a.Error(err)
+		}
+	}
+	_, err := NewGCMSIV(make([]byte, 24))
+	a.Equal(KeySizeError(24), err)
+}
@@ -0,0 +1,166 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// IntegrityError is returned by Unwrap and UnwrapPad when the unwrapped key
+// fails its integrity check, meaning the wrong KEK or a corrupted input.
+type IntegrityError struct{}
+
+func (IntegrityError) Error() string {
+	return "aes: key unwrap integrity check failed"
+}
+
+var (
+	wrapIV    = [8]byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	wrapPadIV = [4]byte{0xa6, 0x59, 0x59, 0xa6}
+)
+
+// Wrap wraps key under kek as in RFC 3394. key must be at least 16 bytes and
+// a multiple of 8.
+func Wrap(kek cipher.Block, key []byte) ([]byte, error) {
+	if err := checkKEK(kek); err != nil {
+		return nil, err
+	}
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, LengthError(len(key))
+	}
+	return wrap(kek, wrapIV, key), nil
+}
+
+// Unwrap reverses Wrap.
+func Unwrap(kek cipher.Block, wrapped []byte) ([]byte, error) {
+	if err := checkKEK(kek); err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, LengthError(len(wrapped))
+	}
+	a, key := unwrap(kek, wrapped)
+	if subtle.ConstantTimeCompare(a[:], wrapIV[:]) != 1 {
+		return nil, IntegrityError{}
+	}
+	return key, nil
+}
+
+// WrapPad wraps key under kek as in RFC 5649, which accepts any non-empty key
+// length up to 2^32-1 bytes.
+func WrapPad(kek cipher.Block, key []byte) ([]byte, error) {
+	if err := checkKEK(kek); err != nil {
+		return nil, err
+	}
+	if len(key) == 0 || uint64(len(key)) > 1<<32-1 {
+		return nil, LengthError(len(key))
+	}
+	var aiv [8]byte
+	copy(aiv[:], wrapPadIV[:])
+	binary.BigEndian.PutUint32(aiv[4:], uint32(len(key)))
+	p := make([]byte, (len(key)+7)/8*8)
+	copy(p, key)
+	if len(p) == 8 {
+		out := make([]byte, BlockSize)
+		copy(out, aiv[:])
+		copy(out[8:], p)
+		kek.Encrypt(out, out)
+		return out, nil
+	}
+	return wrap(kek, aiv, p), nil
+}
+
+// UnwrapPad reverses WrapPad.
+func UnwrapPad(kek cipher.Block, wrapped []byte) ([]byte, error) {
+	if err := checkKEK(kek); err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, LengthError(len(wrapped))
+	}
+	var a [8]byte
+	var p []byte
+	if len(wrapped) == BlockSize {
+		var b [BlockSize]byte
+		kek.Decrypt(b[:], wrapped)
+		copy(a[:], b[:8])
+		p = b[8:]
+	} else {
+		a, p = unwrap(kek, wrapped)
+	}
+
+	// Check the magic, that the length falls in the last 8-byte block, and
+	// that the padding is zero, without branching on secret data.
+	mli := uint64(binary.BigEndian.Uint32(a[4:]))
+	ok := subtle.ConstantTimeCompare(a[:4], wrapPadIV[:])
+	n := uint64(len(p))
+	ok &= lessOrEq(n-7, mli) & lessOrEq(mli, n)
+	var pad byte
+	for i := n - 7; i < n; i++ {
+		pad |= p[i] & -byte(lessOrEq(mli, i))
+	}
+	ok &= subtle.ConstantTimeByteEq(pad, 0)
+	if ok != 1 {
+		return nil, IntegrityError{}
+	}
+	return p[:mli], nil
+}
+
+// lessOrEq returns 1 if x <= y and 0 otherwise, for x, y < 2^63, in
+// constant time.
+func lessOrEq(x, y uint64) int {
+	return int((y-x)>>63) ^ 1
+}
+
+func checkKEK(kek cipher.Block) error {
+	if kek.BlockSize() != BlockSize {
+		return errors.New("aes: key wrap requires a 16-byte block cipher")
+	}
+	return nil
+}
+
+// wrap runs the RFC 3394 wrapping process W with initial value iv over p,
+// which holds at least two 8-byte blocks.
+func wrap(kek cipher.Block, iv [8]byte, p []byte) []byte {
+	n := len(p) / 8
+	out := make([]byte, 8+len(p))
+	copy(out[8:], p)
+	var b [BlockSize]byte
+	copy(b[:8], iv[:])
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			r := out[8*i : 8*i+8]
+			copy(b[8:], r)
+			kek.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(r, b[8:])
+		}
+	}
+	copy(out, b[:8])
+	return out
+}
+
+// unwrap inverts wrap and returns the recovered initial value for the caller
+// to check.
+func unwrap(kek cipher.Block, c []byte) ([8]byte, []byte) {
+	n := len(c)/8 - 1
+	p := make([]byte, 8*n)
+	copy(p, c[8:])
+	var b [BlockSize]byte
+	copy(b[:8], c[:8])
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			r := p[8*(i-1) : 8*i]
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(b[8:], r)
+			kek.Decrypt(b[:], b[:])
+			copy(r, b[8:])
+		}
+	}
+	var a [8]byte
+	copy(a[:], b[:8])
+	return a, p
+}
@@ -0,0 +1,124 @@
+package aes
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 3394 section 4.
+func TestWrapVectors(t *testing.T) {
+	a := require.New(t)
+	kek128 := "000102030405060708090a0b0c0d0e0f"
+	kek192 := kek128 + "1011121314151617"
+	kek256 := kek128 + "101112131415161718191a1b1c1d1e1f"
+	key128 := "00112233445566778899aabbccddeeff"
+	key192 := key128 + "0001020304050607"
+	key256 := key128 + "000102030405060708090a0b0c0d0e0f"
+	tests := []struct {
+		kek, key, wrapped string
+	}{
+		{kek128, key128, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"},
+		{kek192, key128, "96778b25ae6ca435f92b5b97c050aed2468ab8a17ad84e5d"},
+		{kek256, key128, "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7"},
+		{kek192, key192, "031d33264e15d33268f24ec260743edce1c6c7ddee725a936ba814915c6762d2"},
+		{kek256, key192, "a8f9bc1612c68b3ff6e6f4fbe30e71e4769c8b80a32cb8958cd5d17d6b254da1"},
+		{kek256, key256, "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21"},
+	}
+	for _, test := range tests {
+		kek, err := NewCipher(unhex(test.kek))
+		a.NoError(err)
+		wrapped, err := Wrap(kek, unhex(test.key))
+		a.NoError(err)
+		a.Equal(unhex(test.wrapped), wrapped)
+		key, err := Unwrap(kek, wrapped)
+		a.NoError(err)
+		a.Equal(unhex(test.key), key)
+
+		wrapped[len(wrapped)-1] ^= 1
+		_, err = Unwrap(kek, wrapped)
+		a.Equal(IntegrityError{}, err)
+	}
+}
+
+// RFC 5649 section 6.
+func TestWrapPadVectors(t *testing.T) {
+	a := require.New(t)
+	kek, err := NewCipher(unhex("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8"))
+	a.NoError(err)
+	tests := []struct {
+		key, wrapped string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+	for _, test := range tests {
+		wrapped, err := WrapPad(kek, unhex(test.key))
+		a.NoError(err)
+		a.Equal(unhex(test.wrapped), wrapped)
+		key, err := UnwrapPad(kek, wrapped)
+		a.NoError(err)
+		a.Equal(unhex(test.key), key)
+
+		wrapped[0] ^= 1
+		_, err = UnwrapPad(kek, wrapped)
+		a.Equal(IntegrityError{}, err)
+	}
+}
+
+func TestWrapPad(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	k := make([]byte, 32)
+	rg.Read(k)
+	kek, err := NewCipherWithBackend(k, Bitsliced)
+	a.NoError(err)
+	for n := 1; n < 70; n++ {
+		key := make([]byte, n)
+		rg.Read(key)
+		wrapped, err := WrapPad(kek, key)
+		a.NoError(err)
+		a.Len(wrapped, 8+(n+7)/8*8)
+		got, err := UnwrapPad(kek, wrapped)
+		a.NoError(err)
+		a.Equal(key, got)
+
+		// A padded wrap is not a plain wrap and vice versa.
+		if n >= 16 && n%8 == 0 {
+			_, err = Unwrap(kek, wrapped)
+			a.True(errors.As(err, &IntegrityError{}))
+			plain, err := Wrap(kek, key)
+			a.NoError(err)
+			_, err = UnwrapPad(kek, plain)
+			a.True(errors.As(err, &IntegrityError{}))
+		}
+	}
+
+	// Wrapped blocks whose length or padding is inconsistent with the
+	// message length indicator are rejected.
+	bad := func(aiv string, p []byte) []byte {
+		return wrap(kek, *(*[8]byte)(unhex(aiv)), p)
+	}
+	p := make([]byte, 24)
+	_, err = UnwrapPad(kek, bad("a65959a600000011", p))
+	a.NoError(err)
+	for _, aiv := range []string{"a65959a600000010", "a65959a600000019", "a65959a6ffffffff", "a65959a700000011"} {
+		_, err = UnwrapPad(kek, bad(aiv, p))
+		a.Equal(IntegrityError{}, err, aiv)
+	}
+	p[23] = 1
+	_, err = UnwrapPad(kek, bad("a65959a600000017", p))
+	a.Equal(IntegrityError{}, err)
+
+	_, err = Wrap(kek, make([]byte, 12))
+	a.Equal(LengthError(12), err)
+	_, err = Unwrap(kek, make([]byte, 16))
+	a.Equal(LengthError(16), err)
+	_, err = WrapPad(kek, nil)
+	a.Equal(LengthError(0), err)
+	_, err = UnwrapPad(kek, make([]byte, 20))
+	a.Equal(LengthError(20), err)
+}
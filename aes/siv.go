@@ -0,0 +1,153 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+// SIV is AES-SIV from RFC 5297. It is deterministic: the same plaintext and
+// associated data always give the same ciphertext, so repeating a nonce only
+// reveals that a message repeated. It is safe for concurrent use.
+type SIV struct {
+	// mac holds the S2V block cipher and CMAC subkeys in the reset state.
+	// It is only read; every message copies it.
+	mac cmac
+	ctr cipher.Block
+}
+
+// NewSIV creates an AES-SIV instance from a 32-, 48- or 64-byte key, whose
+// first half keys S2V and second half keys CTR.
+func NewSIV(key []byte) (*SIV, error) {
+	if len(key)%2 != 0 || rounds(len(key)/2) == 0 {
+		return nil, KeySizeError(len(key))
+	}
+	k1, err := NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	mac, err := NewCMAC(k1)
+	if err != nil {
+		return nil, err
+	}
+	return &SIV{mac: *mac.(*cmac), ctr: k2}, nil
+}
+
+// Seal appends the synthetic IV and the encryption of plaintext to dst. Each
+// element of additionalData is authenticated separately; to use a nonce, pass
+// it as the last one.
+func (s *SIV) Seal(dst, plaintext []byte, additionalData ...[]byte) []byte {
+	v := s.s2v(plaintext, additionalData)
+	ret, out := sliceForAppend(dst, BlockSize+len(plaintext))
+	copy(out, v[:])
+	s.crypt(out[BlockSize:], plaintext, v)
+	return ret
+}
+
+// Open reverses Seal.
+func (s *SIV) Open(dst, ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(ciphertext) < BlockSize {
+		return nil, errOpen
+	}
+	var v [BlockSize]byte
+	copy(v[:], ciphertext)
+	pt := make([]byte, len(ciphertext)-BlockSize)
+	s.crypt(pt, ciphertext[BlockSize:], v)
+	want := s.s2v(pt, additionalData)
+	if subtle.ConstantTimeCompare(want[:], v[:]) != 1 {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(pt))
+	copy(out, pt)
+	return ret, nil
+}
+
+// s2v is S2V over the additional data strings followed by the plaintext.
+func (s *SIV) s2v(plaintext []byte, additionalData [][]byte) [BlockSize]byte {
+	m := s.mac
+	var d, t [BlockSize]byte
+	m.sum(d[:], make([]byte, BlockSize))
+	for _, ad := range additionalData {
+		m.sum(t[:], ad)
+		d = dbl(d)
+		for i := range d {
+			d[i] ^= t[i]
+		}
+	}
+
+	m.Reset()
+	if len(plaintext) >= BlockSize {
+		n := len(plaintext) - BlockSize
+		m.Write(plaintext[:n])
+		for i := range t {
+			t[i] = plaintext[n+i] ^ d[i]
+		}
+	} else {
+		d = dbl(d)
+		t = [BlockSize]byte{}
+		copy(t[:], plaintext)
+		t[len(plaintext)] = 0x80
+		for i := range t {
+			t[i] ^= d[i]
+		}
+	}
+	m.Write(t[:])
+	var v [BlockSize]byte
+	m.Sum(v[:0])
+	return v
+}
+
+// crypt runs CTR from the synthetic IV with bits 31 and 63 cleared.
+func (s *SIV) crypt(dst, src []byte, v [BlockSize]byte) {
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+	ctr, err := NewCTR(s.ctr, v[:])
+	if err != nil {
+		panic(err)
+	}
+	ctr.XORKeyStream(dst, src)
+}
+
+// AEAD returns s as a cipher.AEAD taking nonces of nonceSize bytes, which are
+// authenticated after the additional data as RFC 5297 section 3 suggests. A
+// nonceSize of 0 gives deterministic authenticated encryption.
+func (s *SIV) AEAD(nonceSize int) cipher.AEAD {
+	if nonceSize < 0 {
+		panic("aes: negative SIV nonce size")
+	}
+	return sivAEAD{s, nonceSize}
+}
+
+type sivAEAD struct {
+	s         *SIV
+	nonceSize int
+}
+
+func (a sivAEAD) NonceSize() int {
+	return a.nonceSize
+}
+
+func (a sivAEAD) Overhead() int {
+	return BlockSize
+}
+
+func (a sivAEAD) components(nonce, additionalData []byte) [][]byte {
+	if len(nonce) != a.nonceSize {
+		panic("aes: incorrect nonce length given to SIV")
+	}
+	if a.nonceSize == 0 {
+		return [][]byte{additionalData}
+	}
+	return [][]byte{additionalData, nonce}
+}
+
+func (a sivAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	return a.s.Seal(dst, plaintext, a.components(nonce, additionalData)...)
+}
+
+func (a sivAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return a.s.Open(dst, ciphertext, a.components(nonce, additionalData)...)
+}
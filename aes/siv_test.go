@@ -0,0 +1,118 @@
+package aes
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 5297 appendix A.
+func TestSIVVectors(t *testing.T) {
+	a := require.New(t)
+	s, err := NewSIV(unhex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"))
+	a.NoError(err)
+	ad := unhex("101112131415161718191a1b1c1d1e1f2021222324252627")
+	pt := unhex("112233445566778899aabbccddee")
+	want := unhex("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+	a.Equal(want, s.Seal(nil, pt, ad))
+	a.Equal(want, s.AEAD(0).Seal(nil, nil, pt, ad))
+	got, err := s.Open(nil, want, ad)
+	a.NoError(err)
+	a.Equal(pt, got)
+
+	s, err = NewSIV(unhex("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f"))
+	a.NoError(err)
+	ad1 := unhex("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100")
+	ad2 := unhex("102030405060708090a0")
+	nonce := unhex("09f911029d74e35bd84156c5635688c0")
+	pt = unhex("7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553")
+	want = unhex("7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17" +
+		"dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d")
+	a.Equal(want, s.Seal(nil, pt, ad1, ad2, nonce))
+	got, err = s.Open(nil, want, ad1, ad2, nonce)
+	a.NoError(err)
+	a.Equal(pt, got)
+	_, err = s.Open(nil, want, ad1, nonce, ad2)
+	a.Error(err)
+}
+
+func TestSIV(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, size := range []int{32, 48, 64} {
+		key := make([]byte, size)
+		rg.Read(key)
+		s, err := NewSIV(key)
+		a.NoError(err)
+		aead := s.AEAD(12)
+		a.Equal(12, aead.NonceSize())
+		a.Equal(16, aead.Overhead())
+		for n := 0; n < 50; n++ {
+			nonce := make([]byte, 12)
+			pt := make([]byte, n)
+			ad := make([]byte, rg.Intn(40))
+			rg.Read(nonce)
+			rg.Read(pt)
+			rg.Read(ad)
+			ct := aead.Seal(nil, nonce, pt, ad)
+			a.Equal(s.Seal(nil, pt, ad, nonce), ct)
+			got, err := aead.Open(nil, nonce, ct, ad)
+			a.NoError(err)
+			a.True(bytes.Equal(pt, got))
+
+			ct[rg.Intn(len(ct))] ^= 1
+			_, err = aead.Open(nil, nonce, ct, ad)
+			a.Error(err)
+		}
+	}
+	_, err := NewSIV(make([]byte, 16))
+	a.Equal(KeySizeError(16), err)
+}
+
+func TestSIVConcurrent(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 32)
+	rg.Read(key)
+	s, err := NewSIV(key)
+	a.NoError(err)
+	aead := s.AEAD(12)
+
+	const workers = 8
+	var pts, ads, nonces, want [workers][]byte
+	for i := range pts {
+		pts[i] = make([]byte, 16+rg.Intn(64))
+		ads[i] = make([]byte, rg.Intn(40))
+		nonces[i] = make([]byte, 12)
+		rg.Read(pts[i])
+		rg.Read(ads[i])
+		rg.Read(nonces[i])
+		want[i] = aead.Seal(nil, nonces[i], pts[i], ads[i])
+	}
+
+	var ok [workers]bool
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok[i] = true
+			for n := 0; n < 200; n++ {
+				ct := aead.Seal(nil, nonces[i], pts[i], ads[i])
+				pt, err := aead.Open(nil, nonces[i], ct, ads[i])
+				if err != nil || !bytes.Equal(want[i], ct) || !bytes.Equal(pts[i], pt) {
+					ok[i] = false
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := range ok {
+		a.True(ok[i], "worker %d", i)
+	}
+}
@@ -0,0 +1,85 @@
+package aes
+
+import (
+	"encoding/binary"
+
+	"github.com/RainbowDashy/cipher/gf256"
+)
+
+func encryptBlockTTable(w []uint32, dst, src []byte) {
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ w[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ w[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ w[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ w[3]
+
+	nr := len(w)/4 - 1
+	k := 4
+	for r := 1; r < nr; r++ {
+		t0 := gf256.AESTe0[s0>>24] ^ gf256.AESTe1[s1>>16&0xff] ^ gf256.AESTe2[s2>>8&0xff] ^ gf256.AESTe3[s3&0xff] ^ w[k+0]
+		t1 := gf256.AESTe0[s1>>24] ^ gf256.AESTe1[s2>>16&0xff] ^ gf256.AESTe2[s3>>8&0xff] ^ gf256.AESTe3[s0&0xff] ^ w[k+1]
+		t2 := gf256.AESTe0[s2>>24] ^ gf256.AESTe1[s3>>16&0xff] ^ gf256.AESTe2[s0>>8&0xff] ^ gf256.AESTe3[s1&0xff] ^ w[k+2]
+		t3 := gf256.AESTe0[s3>>24] ^ gf256.AESTe1[s0>>16&0xff] ^ gf256.AESTe2[s1>>8&0xff] ^ gf256.AESTe3[s2&0xff] ^ w[k+3]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
+	s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+	s0 ^= w[k+0]
+	s1 ^= w[k+1]
+	s2 ^= w[k+2]
+	s3 ^= w[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:4], s0)
+	binary.BigEndian.PutUint32(dst[4:8], s1)
+	binary.BigEndian.PutUint32(dst[8:12], s2)
+	binary.BigEndian.PutUint32(dst[12:16], s3)
+}
+
+// invKeySchedule converts the encryption schedule w into the schedule of the
+// equivalent inverse cipher: round keys in reverse order, with InvMixColumns
+// applied to all but the first and last.
+func invKeySchedule(w, dw []uint32) {
+	n := len(w)
+	for i := 0; i < n; i += 4 {
+		for j := 0; j < 4; j++ {
+			x := w[n-4-i+j]
+			if i > 0 && i+4 < n {
+				x = invMixColumn(x)
+			}
+			dw[i+j] = x
+		}
+	}
+}
+
+// decryptBlockTTable decrypts with the equivalent inverse cipher schedule dw
+// produced by invKeySchedule.
+func decryptBlockTTable(dw []uint32, dst, src []byte) {
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ dw[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ dw[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ dw[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ dw[3]
+
+	nr := len(dw)/4 - 1
+	k := 4
+	for r := 1; r < nr; r++ {
+		t0 := gf256.AESTd0[s0>>24] ^ gf256.AESTd1[s3>>16&0xff] ^ gf256.AESTd2[s2>>8&0xff] ^ gf256.AESTd3[s1&0xff] ^ dw[k+0]
+		t1 := gf256.AESTd0[s1>>24] ^ gf256.AESTd1[s0>>16&0xff] ^ gf256.AESTd2[s3>>8&0xff] ^ gf256.AESTd3[s2&0xff] ^ dw[k+1]
+		t2 := gf256.AESTd0[s2>>24] ^ gf256.AESTd1[s1>>16&0xff] ^ gf256.AESTd2[s0>>8&0xff] ^ gf256.AESTd3[s3&0xff] ^ dw[k+2]
+		t3 := gf256.AESTd0[s3>>24] ^ gf256.AESTd1[s2>>16&0xff] ^ gf256.AESTd2[s1>>8&0xff] ^ gf256.AESTd3[s0&0xff] ^ dw[k+3]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+	s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+	s0 ^= dw[k+0]
+	s1 ^= dw[k+1]
+	s2 ^= dw[k+2]
+	s3 ^= dw[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:4], s0)
+	binary.BigEndian.PutUint32(dst[4:8], s1)
+	binary.BigEndian.PutUint32(dst[8:12], s2)
+	binary.BigEndian.PutUint32(dst[12:16], s3)
+}
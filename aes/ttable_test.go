@@ -0,0 +1,80 @@
+package aes
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTTable(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	src := make([]byte, BlockSize)
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	for i := 0; i < 300; i++ {
+		key := make([]byte, 16+8*(i%3))
+		rg.Read(key)
+		rg.Read(src)
+		w := make([]uint32, 4*(rounds(len(key))+1))
+		dw := make([]uint32, len(w))
+		keyExpansion(key, w)
+		invKeySchedule(w, dw)
+
+		encryptBlock(w, want, src)
+		encryptBlockTTable(w, dst, src)
+		a.Equal(want, dst)
+
+		decrptyBlock(w, want, src)
+		decryptBlockTTable(dw, dst, src)
+		a.Equal(want, dst)
+	}
+}
+
+func TestTTableBackend(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 32)
+	rg.Read(key)
+	ref, err := NewCipherWithBackend(key, Reference)
+	a.NoError(err)
+	tt, err := NewCipherWithBackend(key, TTable)
+	a.NoError(err)
+
+	src := make([]byte, BlockSize)
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	rg.Read(src)
+	ref.Encrypt(want, src)
+	tt.Encrypt(dst, src)
+	a.Equal(want, dst)
+	tt.Decrypt(dst, dst)
+	a.Equal(src, dst)
+
+	_, err = NewCipherWithBackend(key, Backend(-1))
+	a.Error(err)
+}
+
+func benchmarkBackend(b *testing.B, backend Backend, decrypt bool) {
+	c, err := NewCipherWithBackend(make([]byte, 16), backend)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if decrypt {
+			c.Decrypt(buf, buf)
+		} else {
+			c.Encrypt(buf, buf)
+		}
+	}
+}
+
+func BenchmarkEncryptReference(b *testing.B) { benchmarkBackend(b, Reference, false) }
+func BenchmarkEncryptTTable(b *testing.B)    { benchmarkBackend(b, TTable, false) }
+func BenchmarkDecryptReference(b *testing.B) { benchmarkBackend(b, Reference, true) }
+func BenchmarkDecryptTTable(b *testing.B)    { benchmarkBackend(b, TTable, true) }
@@ -0,0 +1,208 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+)
+
+// XTS is XTS-AES from IEEE 1619. The key is two AES keys of equal length:
+// the first encrypts data and the second encrypts sector numbers into
+// tweaks.
+type XTS struct {
+	k1, k2 cipher.Block
+}
+
+// NewXTS creates an XTS instance from a 32-, 48- or 64-byte key using the
+// Reference backend.
+func NewXTS(key []byte) (*XTS, error) {
+	return NewXTSWithBackend(key, Reference)
+}
+
+// NewXTSWithBackend is NewXTS with an explicit backend.
+func NewXTSWithBackend(key []byte, backend Backend) (*XTS, error) {
+	if len(key)%2 != 0 || rounds(len(key)/2) == 0 {
+		return nil, KeySizeError(len(key))
+	}
+	k1, err := NewCipherWithBackend(key[:len(key)/2], backend)
+	if err != nil {
+		return nil, err
+	}
+	k2, err := NewCipherWithBackend(key[len(key)/2:], backend)
+	if err != nil {
+		return nil, err
+	}
+	return &XTS{k1: k1, k2: k2}, nil
+}
+
+// mulAlpha multiplies t by alpha in GF(2^128), with t read as a
+// little-endian number as in IEEE 1619.
+func mulAlpha(t *[BlockSize]byte) {
+	carry := t[BlockSize-1] >> 7
+	for i := BlockSize - 1; i > 0; i-- {
+		t[i] = t[i]<<1 | t[i-1]>>7
+	}
+	t[0] = t[0]<<1 ^ 0x87&-carry
+}
+
+// EncryptSector encrypts sector src into dst under the sector number. The
+// sector must be at least one block long; a partial final block is handled
+// with ciphertext stealing. dst and src must overlap entirely or not at all.
+func (x *XTS) EncryptSector(dst, src []byte, sector uint64) {
+	x.crypt(dst, src, sector, false)
+}
+
+// DecryptSector reverses EncryptSector.
+func (x *XTS) DecryptSector(dst, src []byte, sector uint64) {
+	x.crypt(dst, src, sector, true)
+}
+
+func (x *XTS) crypt(dst, src []byte, sector uint64, decrypt bool) {
+	if len(src) < BlockSize {
+		panic("aes: XTS sector shorter than a block")
+	}
+	if len(dst) < len(src) {
+		panic("aes: output smaller than input")
+	}
+	var t [BlockSize]byte
+	for i := 0; i < 8; i++ {
+		t[i] = byte(sector >> (8 * i))
+	}
+	x.k2.Encrypt(t[:], t[:])
+
+	block := func(dst, src []byte, t *[BlockSize]byte) {
+		var b [BlockSize]byte
+		for i := range b {
+			b[i] = src[i] ^ t[i]
+		}
+		if decrypt {
+			x.k1.Decrypt(b[:], b[:])
+		} else {
+			x.k1.Encrypt(b[:], b[:])
+		}
+		for i := range b {
+			dst[i] = b[i] ^ t[i]
+		}
+	}
+
+	full := len(src) / BlockSize * BlockSize
+	tail := len(src) - full
+	if tail != 0 {
+		full -= BlockSize
+	}
+	for i := 0; i < full; i += BlockSize {
+		block(dst[i:], src[i:], &t)
+		mulAlpha(&t)
+	}
+	if tail == 0 {
+		return
+	}
+
+	// Steal from the last full block. Decryption must undo the blocks in
+	// the opposite tweak order.
+	t1 := t
+	mulAlpha(&t1)
+	first, second := &t, &t1
+	if decrypt {
+		first, second = second, first
+	}
+	var cc [BlockSize]byte
+	block(cc[:], src[full:], first)
+	var pp [BlockSize]byte
+	copy(pp[:], src[full+BlockSize:])
+	copy(pp[tail:], cc[tail:])
+	copy(dst[full+BlockSize:], cc[:tail])
+	block(dst[full:], pp[:], second)
+}
+
+// ReaderWriterAt is the random access storage behind an Image, such as an
+// *os.File.
+type ReaderWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Image presents storage encrypted sector by sector with XTS as plaintext.
+// Sector n covers bytes [n*sectorSize, (n+1)*sectorSize) of the storage and is
+// encrypted with sector number n. Writes that cover part of a sector read,
+// patch and rewrite the whole sector; sectors past the end of the storage
+// read as zeros for this purpose.
+type Image struct {
+	x          *XTS
+	rw         ReaderWriterAt
+	sectorSize int
+}
+
+// NewImage wraps rw, whose size should be a multiple of sectorSize.
+func NewImage(x *XTS, rw ReaderWriterAt, sectorSize int) (*Image, error) {
+	if sectorSize < BlockSize {
+		return nil, errors.New("aes: XTS sector size must be at least one block")
+	}
+	return &Image{x: x, rw: rw, sectorSize: sectorSize}, nil
+}
+
+func (m *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("aes: Image.ReadAt: negative offset")
+	}
+	ss := int64(m.sectorSize)
+	buf := make([]byte, ss)
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		s := pos / ss
+		k, err := m.rw.ReadAt(buf, s*ss)
+		if k < len(buf) {
+			if k == 0 && err == io.EOF {
+				return n, io.EOF
+			}
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		m.x.DecryptSector(buf, buf, uint64(s))
+		n += copy(p[n:], buf[pos-s*ss:])
+	}
+	return n, nil
+}
+
+func (m *Image) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("aes: Image.WriteAt: negative offset")
+	}
+	ss := int64(m.sectorSize)
+	buf := make([]byte, ss)
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		s := pos / ss
+		in := int(pos - s*ss)
+		c := len(p) - n
+		if c > len(buf)-in {
+			c = len(buf) - in
+		}
+		if c < len(buf) {
+			k, err := m.rw.ReadAt(buf, s*ss)
+			switch {
+			case k == len(buf):
+				m.x.DecryptSector(buf, buf, uint64(s))
+			case k == 0 && err == io.EOF:
+				for i := range buf {
+					buf[i] = 0
+				}
+			case err == nil || err == io.EOF:
+				return n, io.ErrUnexpectedEOF
+			default:
+				return n, err
+			}
+		}
+		copy(buf[in:], p[n:n+c])
+		m.x.EncryptSector(buf, buf, uint64(s))
+		if _, err := m.rw.WriteAt(buf, s*ss); err != nil {
+			return n, err
+		}
+		n += c
+	}
+	return n, nil
+}
@@ -0,0 +1,172 @@
+package aes
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/xts"
+)
+
+// IEEE 1619 Annex B vectors 1, 2 and 15 to 18.
+func TestXTSVectors(t *testing.T) {
+	a := require.New(t)
+	seq := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return string(b)
+	}
+	key := "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0"
+	tests := []struct {
+		key    string
+		sector uint64
+		pt     string
+		ct     string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000", 0,
+			string(make([]byte, 32)),
+			"917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+		},
+		{
+			"1111111111111111111111111111111122222222222222222222222222222222", 0x3333333333,
+			string(bytes.Repeat([]byte{0x44}, 32)),
+			"c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+		},
+		{key, 0x123456789a, seq(17), "6c1625db4671522d3d7599601de7ca09ed"},
+		{key, 0x123456789a, seq(18), "d069444b7a7e0cab09e24447d24deb1fedbf"},
+		{key, 0x123456789a, seq(19), "e5df1351c0544ba1350b3363cd8ef4beedbf9d"},
+		{key, 0x123456789a, seq(20), "9d84c813f719aa2c7be3f66171c7c5c2edbf9dac"},
+	}
+	for _, test := range tests {
+		for _, backend := range []Backend{Reference, TTable, Bitsliced} {
+			x, err := NewXTSWithBackend(unhex(test.key), backend)
+			a.NoError(err)
+			dst := make([]byte, len(test.pt))
+			x.EncryptSector(dst, []byte(test.pt), test.sector)
+			a.Equal(unhex(test.ct), dst)
+			x.DecryptSector(dst, dst, test.sector)
+			a.Equal([]byte(test.pt), dst)
+		}
+	}
+}
+
+func TestXTS(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, size := range []int{32, 64} {
+		key := make([]byte, size)
+		rg.Read(key)
+		x, err := NewXTS(key)
+		a.NoError(err)
+		ref, err := xts.NewCipher(NewCipher, key)
+		a.NoError(err)
+
+		for n := BlockSize; n < 8*BlockSize; n++ {
+			sector := rg.Uint64()
+			src := make([]byte, n)
+			rg.Read(src)
+			dst := make([]byte, n)
+			x.EncryptSector(dst, src, sector)
+			if n%BlockSize == 0 {
+				want := make([]byte, n)
+				ref.Encrypt(want, src, sector)
+				a.Equal(want, dst)
+			}
+			x.DecryptSector(dst, dst, sector)
+			a.Equal(src, dst)
+		}
+	}
+
+	_, err := NewXTS(make([]byte, 16))
+	a.Equal(KeySizeError(16), err)
+	x, err := NewXTS(make([]byte, 48))
+	a.NoError(err)
+	a.Panics(func() { x.EncryptSector(make([]byte, 15), make([]byte, 15), 0) })
+}
+
+// memStorage is a growable in-memory ReaderWriterAt.
+type memStorage struct {
+	b []byte
+}
+
+func (m *memStorage) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memStorage) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(m.b) {
+		m.b = append(m.b, make([]byte, end-len(m.b))...)
+	}
+	return copy(m.b[off:], p), nil
+}
+
+func TestImage(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 32)
+	rg.Read(key)
+	x, err := NewXTSWithBackend(key, TTable)
+	a.NoError(err)
+	const sectorSize = 512
+	storage := &memStorage{}
+	img, err := NewImage(x, storage, sectorSize)
+	a.NoError(err)
+
+	// Format the image first: holes in the storage are zero ciphertext,
+	// which does not decrypt to zeros.
+	plain := make([]byte, 8*sectorSize)
+	_, err = img.WriteAt(plain[:sectorSize+7], 0)
+	a.NoError(err)
+	_, err = img.WriteAt(plain[sectorSize+7:], sectorSize+7)
+	a.NoError(err)
+	for i := 0; i < 100; i++ {
+		off := rg.Intn(len(plain))
+		n := rg.Intn(len(plain) - off)
+		p := make([]byte, n)
+		rg.Read(p)
+		k, err := img.WriteAt(p, int64(off))
+		a.NoError(err)
+		a.Equal(n, k)
+		copy(plain[off:], p)
+
+		off = rg.Intn(len(storage.b))
+		n = rg.Intn(len(storage.b) - off)
+		p = make([]byte, n)
+		k, err = img.ReadAt(p, int64(off))
+		a.NoError(err)
+		a.Equal(n, k)
+		a.Equal(plain[off:off+n], p)
+	}
+	a.Len(storage.b, len(plain))
+
+	// Each stored sector is the XTS encryption of the plaintext sector.
+	for s := 0; s < len(storage.b)/sectorSize; s++ {
+		want := make([]byte, sectorSize)
+		x.EncryptSector(want, plain[s*sectorSize:(s+1)*sectorSize], uint64(s))
+		a.Equal(want, storage.b[s*sectorSize:(s+1)*sectorSize])
+	}
+
+	p := make([]byte, 10)
+	k, err := img.ReadAt(p, int64(len(storage.b)-5))
+	a.Equal(5, k)
+	a.Equal(io.EOF, err)
+	storage.b = storage.b[:len(storage.b)-1]
+	_, err = img.ReadAt(p, int64(len(storage.b)-5))
+	a.Equal(io.ErrUnexpectedEOF, err)
+
+	_, err = NewImage(x, storage, 8)
+	a.Error(err)
+}
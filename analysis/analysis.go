@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/bits"
+	"strconv"
+
+	"github.com/RainbowDashy/cipher/gf256"
+)
+
+// SizeError is returned by New when the table does not describe a 4-bit or
+// 8-bit S-box.
+type SizeError int
+
+func (s SizeError) Error() string {
+	return "analysis: invalid S-box size " + strconv.Itoa(int(s))
+}
+
+// ErrNotPermutation is returned for operations that need an invertible S-box.
+var ErrNotPermutation = errors.New("analysis: S-box is not a permutation")
+
+// SBox is an n-bit S-box for n = 4 or 8.
+type SBox struct {
+	t []byte
+}
+
+// New creates an SBox from its lookup table, which must have 16 or 256
+// entries, all smaller than its length. The table is copied.
+func New(table []byte) (*SBox, error) {
+	n := len(table)
+	if n != 16 && n != 256 {
+		return nil, SizeError(n)
+	}
+	for _, y := range table {
+		if int(y) >= n {
+			return nil, SizeError(n)
+		}
+	}
+	return &SBox{t: append([]byte(nil), table...)}, nil
+}
+
+// AES returns the AES S-box.
+func AES() *SBox {
+	return &SBox{t: append([]byte(nil), gf256.AESSBox[:]...)}
+}
+
+// InvAES returns the inverse AES S-box.
+func InvAES() *SBox {
+	return &SBox{t: append([]byte(nil), gf256.AESInvSBox[:]...)}
+}
+
+// Bits returns the width n of the S-box.
+func (s *SBox) Bits() int {
+	return bits.TrailingZeros(uint(len(s.t)))
+}
+
+// Table returns a copy of the lookup table.
+func (s *SBox) Table() []byte {
+	return append([]byte(nil), s.t...)
+}
+
+// Inverse returns the inverse S-box.
+func (s *SBox) Inverse() (*SBox, error) {
+	inv := make([]byte, len(s.t))
+	seen := make([]bool, len(s.t))
+	for x, y := range s.t {
+		if seen[y] {
+			return nil, ErrNotPermutation
+		}
+		seen[y] = true
+		inv[y] = byte(x)
+	}
+	return &SBox{t: inv}, nil
+}
+
+// DDT returns the difference distribution table, where entry [a][b] counts
+// the x with S(x) ^ S(x^a) = b.
+func (s *SBox) DDT() [][]int {
+	n := len(s.t)
+	ddt := table(n)
+	for a := 0; a < n; a++ {
+		for x := 0; x < n; x++ {
+			ddt[a][s.t[x]^s.t[x^a]]++
+		}
+	}
+	return ddt
+}
+
+// LAT returns the linear approximation table, where entry [a][b] is the
+// number of x with a·x = b·S(x) minus half the inputs.
+func (s *SBox) LAT() [][]int {
+	n := len(s.t)
+	lat := table(n)
+	for a := 0; a < n; a++ {
+		for b := 0; b < n; b++ {
+			c := 0
+			for x := 0; x < n; x++ {
+				c += (bits.OnesCount(uint(a&x)) + bits.OnesCount(uint(b&int(s.t[x])))) & 1
+			}
+			lat[a][b] = n/2 - c
+		}
+	}
+	return lat
+}
+
+// BCT returns the boomerang connectivity table, where entry [a][b] counts
+// the x with S⁻¹(S(x)^b) ^ S⁻¹(S(x^a)^b) = a.
+func (s *SBox) BCT() ([][]int, error) {
+	inv, err := s.Inverse()
+	if err != nil {
+		return nil, err
+	}
+	n := len(s.t)
+	bct := table(n)
+	for a := 0; a < n; a++ {
+		for b := 0; b < n; b++ {
+			for x := 0; x < n; x++ {
+				if int(inv.t[s.t[x]^byte(b)]^inv.t[s.t[x^a]^byte(b)]) == a {
+					bct[a][b]++
+				}
+			}
+		}
+	}
+	return bct, nil
+}
+
+// DifferentialUniformity returns the largest DDT entry with a nonzero input
+// difference.
+func (s *SBox) DifferentialUniformity() int {
+	return maxEntry(s.DDT(), 1, 1, false)
+}
+
+// BoomerangUniformity returns the largest BCT entry with nonzero input and
+// output differences.
+func (s *SBox) BoomerangUniformity() (int, error) {
+	bct, err := s.BCT()
+	if err != nil {
+		return 0, err
+	}
+	return maxEntry(bct, 1, 1, false), nil
+}
+
+// Linearity returns the largest absolute LAT entry with a nonzero output
+// mask.
+func (s *SBox) Linearity() int {
+	return maxEntry(s.LAT(), 0, 1, true)
+}
+
+// Nonlinearity returns the smallest Hamming distance between a nonzero
+// component function and the affine functions.
+func (s *SBox) Nonlinearity() int {
+	return len(s.t)/2 - s.Linearity()
+}
+
+// Degree returns the largest algebraic degree of the coordinate functions.
+func (s *SBox) Degree() int {
+	n := len(s.t)
+	anf := make([]byte, n)
+	deg := 0
+	for j := 0; j < s.Bits(); j++ {
+		for x := range anf {
+			anf[x] = s.t[x] >> j & 1
+		}
+		// Möbius transform from truth table to algebraic normal form.
+		for step := 1; step < n; step <<= 1 {
+			for x := range anf {
+				if x&step != 0 {
+					anf[x] ^= anf[x^step]
+				}
+			}
+		}
+		for u, c := range anf {
+			if c == 1 && bits.OnesCount(uint(u)) > deg {
+				deg = bits.OnesCount(uint(u))
+			}
+		}
+	}
+	return deg
+}
+
+// FixedPoints returns the x with S(x) = x.
+func (s *SBox) FixedPoints() []byte {
+	var fp []byte
+	for x, y := range s.t {
+		if int(y) == x {
+			fp = append(fp, y)
+		}
+	}
+	return fp
+}
+
+// WriteCSV writes t as CSV with a header row of column indices and the row
+// index in front of each row.
+func WriteCSV(w io.Writer, t [][]int) error {
+	cw := csv.NewWriter(w)
+	if len(t) > 0 {
+		header := make([]string, len(t[0])+1)
+		for b := range t[0] {
+			header[b+1] = strconv.Itoa(b)
+		}
+		cw.Write(header)
+	}
+	var record []string
+	for a, row := range t {
+		record = append(record[:0], strconv.Itoa(a))
+		for _, v := range row {
+			record = append(record, strconv.Itoa(v))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func table(n int) [][]int {
+	t := make([][]int, n)
+	for i := range t {
+		t[i] = make([]int, n)
+	}
+	return t
+}
+
+// maxEntry returns the largest entry of t from row a0 and column b0 on, by
+// absolute value if abs is set.
+func maxEntry(t [][]int, a0, b0 int, abs bool) int {
+	m := 0
+	for a := a0; a < len(t); a++ {
+		for b := b0; b < len(t[a]); b++ {
+			v := t[a][b]
+			if abs && v < 0 {
+				v = -v
+			}
+			if v > m {
+				m = v
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,125 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// PRESENT S-box.
+var present = []byte{0xc, 0x5, 0x6, 0xb, 0x9, 0x0, 0xa, 0xd, 0x3, 0xe, 0xf, 0x8, 0x4, 0x7, 0x1, 0x2}
+
+func TestNew(t *testing.T) {
+	a := require.New(t)
+	for _, n := range []int{0, 15, 17, 128, 512} {
+		_, err := New(make([]byte, n))
+		a.Equal(SizeError(n), err)
+	}
+	_, err := New([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 16})
+	a.Equal(SizeError(16), err)
+
+	s, err := New(present)
+	a.NoError(err)
+	a.Equal(4, s.Bits())
+	a.Equal(present, s.Table())
+	a.Equal(8, AES().Bits())
+}
+
+func TestInverse(t *testing.T) {
+	a := require.New(t)
+	inv, err := AES().Inverse()
+	a.NoError(err)
+	a.Equal(InvAES().Table(), inv.Table())
+
+	s, err := New(make([]byte, 16))
+	a.NoError(err)
+	_, err = s.Inverse()
+	a.Equal(ErrNotPermutation, err)
+	_, err = s.BCT()
+	a.Equal(ErrNotPermutation, err)
+}
+
+func TestAES(t *testing.T) {
+	a := require.New(t)
+	for _, s := range []*SBox{AES(), InvAES()} {
+		ddt := s.DDT()
+		a.Equal(256, ddt[0][0])
+		for _, row := range ddt {
+			sum := 0
+			for _, v := range row {
+				a.Zero(v % 2)
+				sum += v
+			}
+			a.Equal(256, sum)
+		}
+		a.Equal(4, s.DifferentialUniformity())
+		a.Equal(16, s.Linearity())
+		a.Equal(112, s.Nonlinearity())
+		a.Equal(7, s.Degree())
+		a.Empty(s.FixedPoints())
+		bu, err := s.BoomerangUniformity()
+		a.NoError(err)
+		a.Equal(6, bu)
+	}
+	// The LAT of the inverse is the transposed LAT.
+	lat, inv := AES().LAT(), InvAES().LAT()
+	for i := range lat {
+		for j := range lat {
+			a.Equal(lat[i][j], inv[j][i])
+		}
+	}
+	a.Equal(128, lat[0][0])
+}
+
+func TestPresent(t *testing.T) {
+	a := require.New(t)
+	s, err := New(present)
+	a.NoError(err)
+	a.Equal(4, s.DifferentialUniformity())
+	a.Equal(4, s.Nonlinearity())
+	a.Equal(3, s.Degree())
+	a.Empty(s.FixedPoints())
+	// The BCT dominates the DDT.
+	ddt := s.DDT()
+	bct, err := s.BCT()
+	a.NoError(err)
+	for i := range ddt {
+		for j := range ddt {
+			a.GreaterOrEqual(bct[i][j], ddt[i][j])
+		}
+	}
+
+	id := make([]byte, 16)
+	for i := range id {
+		id[i] = byte(i)
+	}
+	s, err = New(id)
+	a.NoError(err)
+	a.Equal(16, s.DifferentialUniformity())
+	a.Equal(0, s.Nonlinearity())
+	a.Equal(1, s.Degree())
+	a.Len(s.FixedPoints(), 16)
+}
+
+func TestWriteCSV(t *testing.T) {
+	a := require.New(t)
+	s, err := New(present)
+	a.NoError(err)
+	lat := s.LAT()
+	var buf bytes.Buffer
+	a.NoError(WriteCSV(&buf, lat))
+	records, err := csv.NewReader(&buf).ReadAll()
+	a.NoError(err)
+	a.Len(records, 17)
+	a.Equal("", records[0][0])
+	a.Equal("15", records[0][16])
+	for i, row := range lat {
+		a.Equal(strconv.Itoa(i), records[i+1][0])
+		for j, v := range row {
+			a.Equal(strconv.Itoa(v), records[i+1][j+1])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Command maesworker searches maes key ranges handed out by maes.Coordinate.
+//
+// By default it serves a single coordinator over stdin and stdout. With
+// -listen it accepts TCP connections instead and serves each one.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/RainbowDashy/cipher/maes"
+)
+
+func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines per job")
+	listen := flag.String("listen", "", "TCP address to serve instead of stdin/stdout")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *listen == "" {
+		if err := maes.ServeWorker(ctx, os.Stdin, os.Stdout, maes.WithWorkers(*workers)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Fatal(err)
+		}
+		go func() {
+			defer conn.Close()
+			if err := maes.ServeWorker(ctx, conn, conn, maes.WithWorkers(*workers)); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,47 @@
+package gf256
+
+// Tables of the AES field shared by the ciphers in this module. Callers must
+// not modify them.
+var (
+	// AESSBox and AESInvSBox are the S-box and its inverse, FIPS-197 Figures
+	// 7 and 14.
+	AESSBox, AESInvSBox = aesField.SBox(AESAffine())
+
+	// AESMul2 to AESMul14 multiply by the MixColumns and InvMixColumns
+	// coefficients.
+	AESMul2  = aesField.MulTable(2)
+	AESMul3  = aesField.MulTable(3)
+	AESMul9  = aesField.MulTable(9)
+	AESMul11 = aesField.MulTable(11)
+	AESMul13 = aesField.MulTable(13)
+	AESMul14 = aesField.MulTable(14)
+
+	// AESRcon holds the key schedule round constants, where AESRcon[i] is
+	// x^(i-1) for i from 1 to 14. AESRcon[0] is 0 and unused.
+	AESRcon = aesRcon()
+
+	// AESTe0 to AESTe3 combine SubBytes and MixColumns for one input byte in
+	// row 0 to 3, and AESTd0 to AESTd3 InvSubBytes and InvMixColumns. Tables
+	// 1 to 3 are byte rotations of table 0.
+	AESTe0, AESTe1, AESTe2, AESTe3 = aesTTables(&AESSBox, [4]byte{2, 1, 1, 3})
+	AESTd0, AESTd1, AESTd2, AESTd3 = aesTTables(&AESInvSBox, [4]byte{14, 9, 13, 11})
+)
+
+func aesRcon() [15]byte {
+	var r [15]byte
+	for i := 1; i < len(r); i++ {
+		r[i] = aesField.Pow(2, i-1)
+	}
+	return r
+}
+
+// aesTTables multiplies the S-box output by the column c, most significant
+// byte first, and rotates the result for the other rows.
+func aesTTables(sbox *[256]byte, c [4]byte) (t0, t1, t2, t3 [256]uint32) {
+	for x := range t0 {
+		s := sbox[x]
+		t := uint32(aesField.Mul(c[0], s))<<24 | uint32(aesField.Mul(c[1], s))<<16 | uint32(aesField.Mul(c[2], s))<<8 | uint32(aesField.Mul(c[3], s))
+		t0[x], t1[x], t2[x], t3[x] = t, t>>8|t<<24, t>>16|t<<16, t>>24|t<<8
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package gf256
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESTables(t *testing.T) {
+	a := require.New(t)
+	a.Equal(sbox0, AESSBox)
+	a.Equal(sbox1, AESInvSBox)
+	f := AES()
+	for c, m := range map[byte][256]byte{2: AESMul2, 3: AESMul3, 9: AESMul9, 11: AESMul11, 13: AESMul13, 14: AESMul14} {
+		a.Equal(f.MulTable(c), m)
+	}
+	// FIPS-197 Section 5.2, continued for key schedules longer than AES-128.
+	a.Equal([15]byte{0, 0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36, 0x6c, 0xd8, 0xab, 0x4d}, AESRcon)
+}
+
+func TestAESTTables(t *testing.T) {
+	a := require.New(t)
+	for x := 0; x < 256; x++ {
+		s := AESSBox[x]
+		a.Equal(uint32(AESMul2[s])<<24|uint32(s)<<16|uint32(s)<<8|uint32(AESMul3[s]), AESTe0[x])
+		s = AESInvSBox[x]
+		a.Equal(uint32(AESMul14[s])<<24|uint32(AESMul9[s])<<16|uint32(AESMul13[s])<<8|uint32(AESMul11[s]), AESTd0[x])
+		for i, tt := range [][2]*[256]uint32{{&AESTe0, &AESTe1}, {&AESTe1, &AESTe2}, {&AESTe2, &AESTe3}, {&AESTd0, &AESTd1}, {&AESTd1, &AESTd2}, {&AESTd2, &AESTd3}} {
+			a.Equal(tt[0][x]>>8|tt[0][x]<<24, tt[1][x], "table pair %d", i)
+		}
+	}
+	a.Equal(uint32(0xc66363a5), AESTe0[0])
+	a.Equal(uint32(0x51f4a750), AESTd0[0])
+}
@@ -0,0 +1,203 @@
+package gf256
+
+import (
+	"math/bits"
+	"strconv"
+)
+
+// PolynomialError is returned by New when the modulus is not an irreducible
+// polynomial of degree 8.
+type PolynomialError uint16
+
+func (p PolynomialError) Error() string {
+	return "gf256: invalid polynomial 0x" + strconv.FormatUint(uint64(p), 16)
+}
+
+// Field is GF(2^8) built as GF(2)[x] modulo an irreducible polynomial. Bit i
+// of an element is the coefficient of x^i.
+type Field struct {
+	poly uint16
+	gen  byte
+	log  [256]int
+	// exp holds two periods so Mul can skip the reduction mod 255.
+	exp [510]byte
+}
+
+// New creates the field modulo poly, which must have degree 8 and be
+// irreducible. The log and antilog tables use the smallest generator of the
+// multiplicative group.
+func New(poly uint16) (*Field, error) {
+	if poly>>8 != 1 {
+		return nil, PolynomialError(poly)
+	}
+	for d := uint16(2); d < 32; d++ {
+		if polyMod(poly, d) == 0 {
+			return nil, PolynomialError(poly)
+		}
+	}
+	f := &Field{poly: poly}
+	for g := 2; g < 256; g++ {
+		if f.order(byte(g)) == 255 {
+			f.gen = byte(g)
+			break
+		}
+	}
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		f.exp[i] = x
+		f.exp[i+255] = x
+		f.log[x] = i
+		x = f.mulSlow(x, f.gen)
+	}
+	return f, nil
+}
+
+var aesField, _ = New(0x11b)
+
+// AES returns the field modulo x^8 + x^4 + x^3 + x + 1 used by AES.
+func AES() *Field {
+	return aesField
+}
+
+// Poly returns the modulus.
+func (f *Field) Poly() uint16 {
+	return f.poly
+}
+
+// Generator returns the generator behind Log and Exp.
+func (f *Field) Generator() byte {
+	return f.gen
+}
+
+// Add returns a + b, which is also a - b.
+func (f *Field) Add(a, b byte) byte {
+	return a ^ b
+}
+
+// Mul returns a * b.
+func (f *Field) Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return f.exp[f.log[a]+f.log[b]]
+}
+
+// Inv returns the multiplicative inverse of a. As in AES, 0 maps to 0.
+func (f *Field) Inv(a byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return f.exp[255-f.log[a]]
+}
+
+// Pow returns a^n. Negative n raise the inverse of a, and a^0 is 1 for every
+// a including 0.
+func (f *Field) Pow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return f.Exp(f.log[a] * (n % 255))
+}
+
+// Log returns the discrete logarithm of a to base Generator, in [0, 255).
+// It panics if a is 0.
+func (f *Field) Log(a byte) int {
+	if a == 0 {
+		panic("gf256: log of zero")
+	}
+	return f.log[a]
+}
+
+// Exp returns Generator raised to n.
+func (f *Field) Exp(n int) byte {
+	n %= 255
+	if n < 0 {
+		n += 255
+	}
+	return f.exp[n]
+}
+
+// MulTable returns the table of x -> c * x.
+func (f *Field) MulTable(c byte) [256]byte {
+	var t [256]byte
+	for x := range t {
+		t[x] = f.Mul(c, byte(x))
+	}
+	return t
+}
+
+// Affine is the map x -> Mx + C over GF(2)^8, where bit i of the result is the
+// parity of M[i] & x plus bit i of C.
+type Affine struct {
+	M [8]byte
+	C byte
+}
+
+// AESAffine returns the affine map of the AES S-box from FIPS-197 (5.1).
+func AESAffine() Affine {
+	a := Affine{C: 0x63}
+	for i := range a.M {
+		a.M[i] = bits.RotateLeft8(0xf1, i)
+	}
+	return a
+}
+
+// Apply returns the image of x.
+func (a Affine) Apply(x byte) byte {
+	y := a.C
+	for i, m := range a.M {
+		y ^= byte(bits.OnesCount8(m&x)&1) << i
+	}
+	return y
+}
+
+// SBox returns the S-box x -> a(x^-1) and its inverse. The inverse is only
+// meaningful if a is invertible.
+func (f *Field) SBox(a Affine) (sbox, inv [256]byte) {
+	for x := range sbox {
+		y := a.Apply(f.Inv(byte(x)))
+		sbox[x] = y
+		inv[y] = byte(x)
+	}
+	return sbox, inv
+}
+
+// order returns the multiplicative order of a nonzero a.
+func (f *Field) order(a byte) int {
+	x := a
+	n := 1
+	for x != 1 {
+		x = f.mulSlow(x, a)
+		n++
+	}
+	return n
+}
+
+// mulSlow multiplies by shift and add, reducing as it goes.
+func (f *Field) mulSlow(a, b byte) byte {
+	var p byte
+	x := uint16(a)
+	for b != 0 {
+		if b&1 != 0 {
+			p ^= byte(x)
+		}
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= f.poly
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// polyMod returns a mod b over GF(2)[x].
+func polyMod(a, b uint16) uint16 {
+	db := bits.Len16(b)
+	for bits.Len16(a) >= db {
+		a ^= b << (bits.Len16(a) - db)
+	}
+	return a
+}
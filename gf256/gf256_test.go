@@ -0,0 +1,135 @@
+package gf256
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FIPS-197 Figure 7. S-box substitution values in hexadecimal format.
+var sbox0 = [256]byte{
+	0x63, 0x7c, 0x77, 0x7b, 0xf2, 0x6b, 0x6f, 0xc5, 0x30, 0x01, 0x67, 0x2b, 0xfe, 0xd7, 0xab, 0x76,
+	0xca, 0x82, 0xc9, 0x7d, 0xfa, 0x59, 0x47, 0xf0, 0xad, 0xd4, 0xa2, 0xaf, 0x9c, 0xa4, 0x72, 0xc0,
+	0xb7, 0xfd, 0x93, 0x26, 0x36, 0x3f, 0xf7, 0xcc, 0x34, 0xa5, 0xe5, 0xf1, 0x71, 0xd8, 0x31, 0x15,
+	0x04, 0xc7, 0x23, 0xc3, 0x18, 0x96, 0x05, 0x9a, 0x07, 0x12, 0x80, 0xe2, 0xeb, 0x27, 0xb2, 0x75,
+	0x09, 0x83, 0x2c, 0x1a, 0x1b, 0x6e, 0x5a, 0xa0, 0x52, 0x3b, 0xd6, 0xb3, 0x29, 0xe3, 0x2f, 0x84,
+	0x53, 0xd1, 0x00, 0xed, 0x20, 0xfc, 0xb1, 0x5b, 0x6a, 0xcb, 0xbe, 0x39, 0x4a, 0x4c, 0x58, 0xcf,
+	0xd0, 0xef, 0xaa, 0xfb, 0x43, 0x4d, 0x33, 0x85, 0x45, 0xf9, 0x02, 0x7f, 0x50, 0x3c, 0x9f, 0xa8,
+	0x51, 0xa3, 0x40, 0x8f, 0x92, 0x9d, 0x38, 0xf5, 0xbc, 0xb6, 0xda, 0x21, 0x10, 0xff, 0xf3, 0xd2,
+	0xcd, 0x0c, 0x13, 0xec, 0x5f, 0x97, 0x44, 0x17, 0xc4, 0xa7, 0x7e, 0x3d, 0x64, 0x5d, 0x19, 0x73,
+	0x60, 0x81, 0x4f, 0xdc, 0x22, 0x2a, 0x90, 0x88, 0x46, 0xee, 0xb8, 0x14, 0xde, 0x5e, 0x0b, 0xdb,
+	0xe0, 0x32, 0x3a, 0x0a, 0x49, 0x06, 0x24, 0x5c, 0xc2, 0xd3, 0xac, 0x62, 0x91, 0x95, 0xe4, 0x79,
+	0xe7, 0xc8, 0x37, 0x6d, 0x8d, 0xd5, 0x4e, 0xa9, 0x6c, 0x56, 0xf4, 0xea, 0x65, 0x7a, 0xae, 0x08,
+	0xba, 0x78, 0x25, 0x2e, 0x1c, 0xa6, 0xb4, 0xc6, 0xe8, 0xdd, 0x74, 0x1f, 0x4b, 0xbd, 0x8b, 0x8a,
+	0x70, 0x3e, 0xb5, 0x66, 0x48, 0x03, 0xf6, 0x0e, 0x61, 0x35, 0x57, 0xb9, 0x86, 0xc1, 0x1d, 0x9e,
+	0xe1, 0xf8, 0x98, 0x11, 0x69, 0xd9, 0x8e, 0x94, 0x9b, 0x1e, 0x87, 0xe9, 0xce, 0x55, 0x28, 0xdf,
+	0x8c, 0xa1, 0x89, 0x0d, 0xbf, 0xe6, 0x42, 0x68, 0x41, 0x99, 0x2d, 0x0f, 0xb0, 0x54, 0xbb, 0x16,
+}
+
+// FIPS-197 Figure 14.  Inverse S-box substitution values in hexadecimal format.
+var sbox1 = [256]byte{
+	0x52, 0x09, 0x6a, 0xd5, 0x30, 0x36, 0xa5, 0x38, 0xbf, 0x40, 0xa3, 0x9e, 0x81, 0xf3, 0xd7, 0xfb,
+	0x7c, 0xe3, 0x39, 0x82, 0x9b, 0x2f, 0xff, 0x87, 0x34, 0x8e, 0x43, 0x44, 0xc4, 0xde, 0xe9, 0xcb,
+	0x54, 0x7b, 0x94, 0x32, 0xa6, 0xc2, 0x23, 0x3d, 0xee, 0x4c, 0x95, 0x0b, 0x42, 0xfa, 0xc3, 0x4e,
+	0x08, 0x2e, 0xa1, 0x66, 0x28, 0xd9, 0x24, 0xb2, 0x76, 0x5b, 0xa2, 0x49, 0x6d, 0x8b, 0xd1, 0x25,
+	0x72, 0xf8, 0xf6, 0x64, 0x86, 0x68, 0x98, 0x16, 0xd4, 0xa4, 0x5c, 0xcc, 0x5d, 0x65, 0xb6, 0x92,
+	0x6c, 0x70, 0x48, 0x50, 0xfd, 0xed, 0xb9, 0xda, 0x5e, 0x15, 0x46, 0x57, 0xa7, 0x8d, 0x9d, 0x84,
+	0x90, 0xd8, 0xab, 0x00, 0x8c, 0xbc, 0xd3, 0x0a, 0xf7, 0xe4, 0x58, 0x05, 0xb8, 0xb3, 0x45, 0x06,
+	0xd0, 0x2c, 0x1e, 0x8f, 0xca, 0x3f, 0x0f, 0x02, 0xc1, 0xaf, 0xbd, 0x03, 0x01, 0x13, 0x8a, 0x6b,
+	0x3a, 0x91, 0x11, 0x41, 0x4f, 0x67, 0xdc, 0xea, 0x97, 0xf2, 0xcf, 0xce, 0xf0, 0xb4, 0xe6, 0x73,
+	0x96, 0xac, 0x74, 0x22, 0xe7, 0xad, 0x35, 0x85, 0xe2, 0xf9, 0x37, 0xe8, 0x1c, 0x75, 0xdf, 0x6e,
+	0x47, 0xf1, 0x1a, 0x71, 0x1d, 0x29, 0xc5, 0x89, 0x6f, 0xb7, 0x62, 0x0e, 0xaa, 0x18, 0xbe, 0x1b,
+	0xfc, 0x56, 0x3e, 0x4b, 0xc6, 0xd2, 0x79, 0x20, 0x9a, 0xdb, 0xc0, 0xfe, 0x78, 0xcd, 0x5a, 0xf4,
+	0x1f, 0xdd, 0xa8, 0x33, 0x88, 0x07, 0xc7, 0x31, 0xb1, 0x12, 0x10, 0x59, 0x27, 0x80, 0xec, 0x5f,
+	0x60, 0x51, 0x7f, 0xa9, 0x19, 0xb5, 0x4a, 0x0d, 0x2d, 0xe5, 0x7a, 0x9f, 0x93, 0xc9, 0x9c, 0xef,
+	0xa0, 0xe0, 0x3b, 0x4d, 0xae, 0x2a, 0xf5, 0xb0, 0xc8, 0xeb, 0xbb, 0x3c, 0x83, 0x53, 0x99, 0x61,
+	0x17, 0x2b, 0x04, 0x7e, 0xba, 0x77, 0xd6, 0x26, 0xe1, 0x69, 0x14, 0x63, 0x55, 0x21, 0x0c, 0x7d,
+}
+
+func TestNew(t *testing.T) {
+	a := require.New(t)
+	for _, p := range []uint16{0, 0x1b, 0x11a, 0x100, 0x101, 0x1ff, 0x21b} {
+		_, err := New(p)
+		a.Equal(PolynomialError(p), err)
+	}
+	// All 30 irreducible polynomials of degree 8.
+	n := 0
+	for p := uint16(0x100); p < 0x200; p++ {
+		if _, err := New(p); err == nil {
+			n++
+		}
+	}
+	a.Equal(30, n)
+
+	f, err := New(0x11d)
+	a.NoError(err)
+	a.Equal(byte(2), f.Generator())
+	a.Equal(uint16(0x11b), AES().Poly())
+	a.Equal(byte(3), AES().Generator())
+}
+
+func TestArithmetic(t *testing.T) {
+	a := require.New(t)
+	for _, p := range []uint16{0x11b, 0x11d, 0x163} {
+		f, err := New(p)
+		a.NoError(err)
+		for x := 0; x < 256; x++ {
+			for y := 0; y < 256; y++ {
+				a.Equal(f.mulSlow(byte(x), byte(y)), f.Mul(byte(x), byte(y)))
+			}
+			a.Equal(byte(x)^0x5a, f.Add(byte(x), 0x5a))
+			if x == 0 {
+				a.Equal(byte(0), f.Inv(0))
+				continue
+			}
+			a.Equal(byte(1), f.Mul(byte(x), f.Inv(byte(x))))
+			a.Equal(byte(x), f.Exp(f.Log(byte(x))))
+			a.Equal(f.Inv(byte(x)), f.Pow(byte(x), -1))
+			a.Equal(f.Inv(byte(x)), f.Pow(byte(x), 254))
+			a.Equal(f.Mul(byte(x), f.Mul(byte(x), byte(x))), f.Pow(byte(x), 3))
+			a.Equal(byte(1), f.Pow(byte(x), 255))
+		}
+		a.Equal(byte(1), f.Pow(0, 0))
+		a.Equal(byte(0), f.Pow(0, 5))
+		a.Equal(f.Exp(254), f.Exp(-1))
+		a.Panics(func() { f.Log(0) })
+	}
+
+	// FIPS-197 Section 4.2.
+	f := AES()
+	a.Equal(byte(0xc1), f.Mul(0x57, 0x83))
+	a.Equal(byte(0xfe), f.Mul(0x57, 0x13))
+	a.Equal(byte(0xca), f.Inv(0x53))
+}
+
+func TestMulTable(t *testing.T) {
+	a := require.New(t)
+	xtime := func(b byte) byte {
+		if b&0x80 != 0 {
+			return b<<1 ^ 0x1b
+		}
+		return b << 1
+	}
+	m2, m3 := AES().MulTable(2), AES().MulTable(3)
+	m9, m11 := AES().MulTable(9), AES().MulTable(11)
+	m13, m14 := AES().MulTable(13), AES().MulTable(14)
+	for x := 0; x < 256; x++ {
+		b := byte(x)
+		b2 := xtime(b)
+		b4 := xtime(b2)
+		b8 := xtime(b4)
+		a.Equal(b2, m2[x])
+		a.Equal(b2^b, m3[x])
+		a.Equal(b8^b, m9[x])
+		a.Equal(b8^b2^b, m11[x])
+		a.Equal(b8^b4^b, m13[x])
+		a.Equal(b8^b4^b2, m14[x])
+	}
+}
+
+func TestSBox(t *testing.T) {
+	a := require.New(t)
+	s, inv := AES().SBox(AESAffine())
+	a.Equal(sbox0, s)
+	a.Equal(sbox1, inv)
+}
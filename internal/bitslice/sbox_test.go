@@ -0,0 +1,28 @@
+package bitslice
+
+import (
+	"testing"
+
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSbox(t *testing.T) {
+	a := require.New(t)
+	for n := 0; n < 4; n++ {
+		var q [8]uint64
+		for k := range q {
+			for j := 0; j < 64; j++ {
+				q[k] |= uint64((64*n+j)>>k&1) << j
+			}
+		}
+		Sbox(&q)
+		for j := 0; j < 64; j++ {
+			var s byte
+			for k := range q {
+				s |= byte(q[k]>>j&1) << k
+			}
+			a.Equal(gf256.AESSBox[64*n+j], s)
+		}
+	}
+}
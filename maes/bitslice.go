@@ -0,0 +1,252 @@
+package maes
+
+import (
+	"encoding/binary"
+
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/RainbowDashy/cipher/internal/bitslice"
+)
+
+// In bitsliced form a 32-bit word is held as 32 uint64 lanes, one per bit,
+// where bit j of every lane belongs to candidate j. Bit 8*i+k of a bsWord is
+// bit k (0 is least significant) of byte i, and byte 0 is the most
+// significant byte as elsewhere in this package.
+type bsWord [32]uint64
+
+// bsConst broadcasts t to every lane.
+func bsConst(t uint32) bsWord {
+	var w bsWord
+	for i := 0; i < 4; i++ {
+		b := byte(t >> (24 - 8*i))
+		for k := 0; k < 8; k++ {
+			w[8*i+k] = -uint64(b >> k & 1)
+		}
+	}
+	return w
+}
+
+func (w *bsWord) xor(v *bsWord) {
+	for i := range w {
+		w[i] ^= v[i]
+	}
+}
+
+// byteAt returns byte i of w.
+func (w *bsWord) byteAt(i int) *[8]uint64 {
+	return (*[8]uint64)(w[8*i : 8*i+8])
+}
+
+func bsSubw(w *bsWord) {
+	for i := 0; i < 4; i++ {
+		bitslice.Sbox(w.byteAt(i))
+	}
+}
+
+// bsRotw sets dst to rotw of src in bitsliced form.
+func bsRotw(dst, src *bsWord) {
+	copy(dst[0:24], src[8:32])
+	copy(dst[24:32], src[0:8])
+}
+
+// bsXtime multiplies a byte by x in GF(2^8).
+func bsXtime(b *[8]uint64) {
+	h := b[7]
+	b[7], b[6], b[5], b[4], b[3], b[2], b[1], b[0] = b[6], b[5], b[4], b[3]^h, b[2]^h, b[1], b[0]^h, h
+}
+
+func bsXor(dst, src *[8]uint64) {
+	for k := range dst {
+		dst[k] ^= src[k]
+	}
+}
+
+func bsMixColumn(w *bsWord) {
+	b0, b1, b2, b3 := w.byteAt(0), w.byteAt(1), w.byteAt(2), w.byteAt(3)
+	// xi is bi ^ b(i+1) and t the XOR of all four bytes.
+	var x0, x1, x2, x3, t [8]uint64
+	for k := range t {
+		x0[k] = b0[k] ^ b1[k]
+		x1[k] = b1[k] ^ b2[k]
+		x2[k] = b2[k] ^ b3[k]
+		x3[k] = b3[k] ^ b0[k]
+		t[k] = x0[k] ^ x2[k]
+	}
+	bsXtime(&x0)
+	bsXtime(&x1)
+	bsXtime(&x2)
+	bsXtime(&x3)
+	for k := range t {
+		b0[k] ^= t[k] ^ x0[k]
+		b1[k] ^= t[k] ^ x1[k]
+		b2[k] ^= t[k] ^ x2[k]
+		b3[k] ^= t[k] ^ x3[k]
+	}
+}
+
+// bsInvMixColumn uses InvMixColumns = MixColumns * (5 0 4 0) circulant, where
+// the right factor adds 4*(b0^b2) to the even bytes and 4*(b1^b3) to the odd
+// ones.
+func bsInvMixColumn(w *bsWord) {
+	b0, b1, b2, b3 := w.byteAt(0), w.byteAt(1), w.byteAt(2), w.byteAt(3)
+	var u, v [8]uint64
+	for k := range u {
+		u[k] = b0[k] ^ b2[k]
+		v[k] = b1[k] ^ b3[k]
+	}
+	bsXtime(&u)
+	bsXtime(&u)
+	bsXtime(&v)
+	bsXtime(&v)
+	bsXor(b0, &u)
+	bsXor(b1, &v)
+	bsXor(b2, &u)
+	bsXor(b3, &v)
+	bsMixColumn(w)
+}
+
+// bsShiftRows is shiftRows on a bitsliced state. Row i moves i columns to
+// the left.
+func bsShiftRows(s *[4]bsWord) {
+	r0, r1, r2, r3 := s[0].byteAt(1), s[1].byteAt(1), s[2].byteAt(1), s[3].byteAt(1)
+	*r0, *r1, *r2, *r3 = *r1, *r2, *r3, *r0
+	r0, r1, r2, r3 = s[0].byteAt(2), s[1].byteAt(2), s[2].byteAt(2), s[3].byteAt(2)
+	*r0, *r1, *r2, *r3 = *r2, *r3, *r0, *r1
+	r0, r1, r2, r3 = s[0].byteAt(3), s[1].byteAt(3), s[2].byteAt(3), s[3].byteAt(3)
+	*r0, *r1, *r2, *r3 = *r3, *r0, *r1, *r2
+}
+
+// bsGuesser runs the staged filter of guesser.guess on 64 consecutive
+// candidates at once and hands the survivors to the table-based guesser.
+type bsGuesser struct {
+	g *guesser
+	// x9 and x10 are the candidate-independent parts of w[9] and w[10], and
+	// c11 and c12 those of w[11] and w[12].
+	x9, x10, c11, c12 bsWord
+	// p holds the plaintext columns XORed with the round 0 tweak, wt the
+	// tweak schedule and rcon the key schedule constants.
+	p    [4]bsWord
+	wt   [40]bsWord
+	rcon [3]bsWord
+	// low holds the lanes of the low six candidate bits for an aligned batch.
+	low [6]uint64
+	// a, w, s and t are scratch space for filter.
+	a, t bsWord
+	w    [13]bsWord
+	s    [4]bsWord
+}
+
+func newBsGuesser(g *guesser) *bsGuesser {
+	c := g.ciphertext
+	word := func(b0, b1, b2, b3 byte) uint32 {
+		return uint32(b0)<<24 | uint32(b1)<<16 | uint32(b2)<<8 | uint32(b3)
+	}
+	bg := &bsGuesser{
+		g:   g,
+		x9:  bsConst(word(gf256.AESInvSBox[c[0]], gf256.AESInvSBox[c[13]], gf256.AESInvSBox[c[10]], gf256.AESInvSBox[c[7]])),
+		x10: bsConst(word(gf256.AESInvSBox[c[4]], gf256.AESInvSBox[c[1]], gf256.AESInvSBox[c[14]], gf256.AESInvSBox[c[11]])),
+		c11: bsConst(word(c[8], c[5], c[2], c[15])),
+		c12: bsConst(word(c[12], c[9], c[6], c[3])),
+	}
+	for j := range bg.p {
+		bg.p[j] = bsConst(binary.BigEndian.Uint32(g.plaintext[4*j:]) ^ g.wt[j])
+	}
+	for i := range bg.wt {
+		bg.wt[i] = bsConst(g.wt[i])
+	}
+	for i := range bg.rcon {
+		bg.rcon[i] = bsConst(uint32(gf256.AESRcon[i+1]) << 24)
+	}
+	for k := range bg.low {
+		for j := 0; j < 64; j++ {
+			bg.low[k] |= uint64(j>>k&1) << j
+		}
+	}
+	return bg
+}
+
+// filter returns the lanes of the candidates base to base+63 that pass the
+// staged check. base must be a multiple of 64.
+func (bg *bsGuesser) filter(base uint32) uint64 {
+	a := &bg.a
+	// Bit k of the candidate is bit k%8 of byte 3-k/8.
+	for k := 0; k < 32; k++ {
+		idx := 8*(3-k/8) + k%8
+		if k < 6 {
+			a[idx] = bg.low[k]
+		} else {
+			a[idx] = -uint64(base >> k & 1)
+		}
+	}
+
+	w := &bg.w
+	t := &bg.t
+	w[9], w[10] = *a, *a
+	w[9].xor(&bg.x9)
+	w[10].xor(&bg.x10)
+	*t = *a
+	bsSubw(t)
+	w[11], w[12] = *t, *t
+	w[11].xor(&bg.c11)
+	w[12].xor(&bg.c12)
+	for i := 8; i >= 0; i-- {
+		w[i] = w[i+4]
+		if i%4 == 0 {
+			bsRotw(t, &w[i+3])
+			bsSubw(t)
+			w[i].xor(t)
+			w[i].xor(&bg.rcon[i/4])
+		} else {
+			w[i].xor(&w[i+3])
+		}
+	}
+
+	// Forward: the first column of the state after round 8.
+	s := &bg.s
+	if bg.g.uniform {
+		s[0] = bg.p[0]
+		s[0].xor(&w[0])
+		for r := 1; r < 9; r++ {
+			bsSubw(&s[0])
+			bsMixColumn(&s[0])
+			s[0].xor(&w[r])
+			s[0].xor(&bg.wt[4*r])
+		}
+	} else {
+		for j := range s {
+			s[j] = bg.p[j]
+			s[j].xor(&w[0])
+		}
+		for r := 1; r < 9; r++ {
+			for j := range s {
+				bsSubw(&s[j])
+			}
+			bsShiftRows(s)
+			for j := range s {
+				bsMixColumn(&s[j])
+				s[j].xor(&w[r])
+				s[j].xor(&bg.wt[4*r+j])
+			}
+		}
+	}
+	s0 := &s[0]
+	bsSubw(s0)
+
+	// Backward: lastRoundsColumn before its final InvSubBytes. u0 and u2
+	// reuse s[1] and s[2], which are no longer needed.
+	u0, u2 := &s[1], &s[2]
+	*u0, *u2 = *a, *a
+	u0.xor(&bg.wt[36])
+	bsInvMixColumn(u0)
+	u2.xor(&bg.wt[38])
+	bsInvMixColumn(u2)
+	*t.byteAt(0) = *u0.byteAt(0)
+	*t.byteAt(1) = *u2.byteAt(1)
+	*t.byteAt(2) = *u2.byteAt(2)
+	*t.byteAt(3) = *u0.byteAt(3)
+
+	var diff uint64
+	for i := range t {
+		diff |= t[i] ^ s0[i]
+	}
+	return ^diff
+}
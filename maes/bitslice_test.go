@@ -0,0 +1,169 @@
+package maes
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/RainbowDashy/cipher/internal/bitslice"
+	"github.com/stretchr/testify/require"
+)
+
+// bsLanes loads the 64 words in ts into the lanes of a bsWord.
+func bsLanes(ts []uint32) bsWord {
+	var w bsWord
+	for j, t := range ts {
+		for i := 0; i < 4; i++ {
+			b := byte(t >> (24 - 8*i))
+			for k := 0; k < 8; k++ {
+				w[8*i+k] |= uint64(b>>k&1) << j
+			}
+		}
+	}
+	return w
+}
+
+// bsLane reads lane j of w back into a word.
+func bsLane(w *bsWord, j int) uint32 {
+	var t uint32
+	for i := 0; i < 4; i++ {
+		for k := 0; k < 8; k++ {
+			t |= uint32(w[8*i+k]>>j&1) << (24 - 8*i + k)
+		}
+	}
+	return t
+}
+
+func randomWords(rg *rand.Rand) []uint32 {
+	ts := make([]uint32, 64)
+	for j := range ts {
+		ts[j] = rg.Uint32()
+	}
+	return ts
+}
+
+func TestBsSbox(t *testing.T) {
+	a := require.New(t)
+	for base := 0; base < 256; base += 64 {
+		ts := make([]uint32, 64)
+		for j := range ts {
+			ts[j] = uint32(base + j)
+		}
+		w := bsLanes(ts)
+		bitslice.Sbox(w.byteAt(3))
+		for j := range ts {
+			a.Equal(uint32(gf256.AESSBox[base+j]), bsLane(&w, j))
+		}
+	}
+}
+
+func TestBsMixColumn(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ts := randomWords(rg)
+	w := bsLanes(ts)
+	bsMixColumn(&w)
+	for j, x := range ts {
+		a.Equal(mixColumn(x), bsLane(&w, j))
+	}
+	w = bsLanes(ts)
+	bsInvMixColumn(&w)
+	for j, x := range ts {
+		a.Equal(invMixColumn(x), bsLane(&w, j))
+	}
+}
+
+func TestBsShiftRows(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var ts [4][]uint32
+	var s [4]bsWord
+	for i := range s {
+		ts[i] = randomWords(rg)
+		s[i] = bsLanes(ts[i])
+	}
+	bsShiftRows(&s)
+	for j := 0; j < 64; j++ {
+		t0, t1, t2, t3 := shiftRows(ts[0][j], ts[1][j], ts[2][j], ts[3][j])
+		a.Equal([4]uint32{t0, t1, t2, t3}, [4]uint32{bsLane(&s[0], j), bsLane(&s[1], j), bsLane(&s[2], j), bsLane(&s[3], j)})
+	}
+}
+
+func TestBsFilter(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	random := make([]byte, 16)
+	rg.Read(random)
+
+	for _, g := range []*guesser{
+		newGuesser(plaintext, ciphertext, tweak, trcon),
+		newGuesser(random, ciphertext, tweak, trcon),
+	} {
+		bg := newBsGuesser(g)
+		bases := []uint32{0xb594aec0}
+		for i := 0; i < 20; i++ {
+			bases = append(bases, rg.Uint32()&^63)
+		}
+		for _, base := range bases {
+			want := uint64(0)
+			for j := 0; j < 64; j++ {
+				c := base + uint32(j)
+				g.guess(c)
+				if g.round8Column(c) == g.lastRoundsColumn(c) {
+					want |= 1 << j
+				}
+			}
+			a.Equal(want, bg.filter(base))
+		}
+	}
+	g := newGuesser(plaintext, ciphertext, tweak, trcon)
+	a.Equal(uint64(1)<<(0xb594aee9-0xb594aec0), newBsGuesser(g).filter(0xb594aec0))
+}
+
+func TestGuessKeyBitsliced(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb5940003, 0xb5950000), WithWorkers(3), WithEngine(BitslicedEngine))
+	a.NoError(err)
+	a.Equal(key, res)
+
+	res, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb594aeea, 0xb594b005), WithEngine(BitslicedEngine))
+	a.Nil(res)
+	a.ErrorIs(err, ErrKeyNotFound)
+}
+
+// BenchmarkGuessBitsliced compares the bitsliced filter with guesser.guess,
+// as in BenchmarkGuesser, for a plaintext that keeps the state columns equal
+// and for one that does not.
+func BenchmarkGuessBitsliced(b *testing.B) {
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	general := []byte("a general block.")
+	for _, bm := range []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"uniform", plaintext},
+		{"general", general},
+	} {
+		g := newGuesser(bm.plaintext, ciphertext, tweak, trcon)
+		b.Run(bm.name+"/guesser", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = g.guess(uint32(i))
+			}
+		})
+		bg := newBsGuesser(g)
+		b.Run(bm.name+"/bitsliced", func(b *testing.B) {
+			b.ReportAllocs()
+			// Each filter call covers 64 candidates, so one op is still one
+			// candidate.
+			for i := 0; i < b.N; i += 64 {
+				_ = bg.filter(uint32(i))
+			}
+		})
+	}
+}
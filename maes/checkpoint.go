@@ -0,0 +1,147 @@
+package maes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrCheckpointMismatch is returned by GuessKey when the checkpoint file was
+// written for a different plaintext, ciphertext, tweak, trcon or range.
+var ErrCheckpointMismatch = errors.New("maes: checkpoint belongs to a different search")
+
+// ErrCheckpointInvalid is returned by GuessKey when the worker ranges in the
+// checkpoint file do not tile the search range.
+var ErrCheckpointInvalid = errors.New("maes: checkpoint has invalid worker ranges")
+
+// WithCheckpoint makes GuessKey resume from the JSON checkpoint at path if it
+// exists, and rewrite it with every worker's position every interval and when
+// the search stops. interval must be positive.
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(c *guessConfig) {
+		c.checkpointPath = path
+		c.checkpointInterval = interval
+	}
+}
+
+// checkpoint is the on-disk form of a search. The problem fields tie it to a
+// single plaintext/ciphertext pair and search range.
+type checkpoint struct {
+	Plaintext  []byte             `json:"plaintext"`
+	Ciphertext []byte             `json:"ciphertext"`
+	Tweak      []byte             `json:"tweak"`
+	Trcon      []uint32           `json:"trcon"`
+	Start      uint64             `json:"start"`
+	End        uint64             `json:"end"`
+	Workers    []checkpointWorker `json:"workers"`
+}
+
+type checkpointWorker struct {
+	Next uint64 `json:"next"`
+	End  uint64 `json:"end"`
+}
+
+func newCheckpoint(plaintext, ciphertext, tweak []byte, trcon []uint32, start, end uint64) *checkpoint {
+	return &checkpoint{
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext,
+		Tweak:      tweak,
+		Trcon:      trcon,
+		Start:      start,
+		End:        end,
+	}
+}
+
+// load reads the checkpoint at path into cp. It reports false if there is no
+// file, ErrCheckpointMismatch if the file describes another problem and
+// ErrCheckpointInvalid if its worker ranges do not cover the search range.
+func (cp *checkpoint) load(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return false, err
+	}
+	if !cp.sameProblem(&saved) {
+		return false, ErrCheckpointMismatch
+	}
+	if !cp.validWorkers(saved.Workers) {
+		return false, ErrCheckpointInvalid
+	}
+	cp.Workers = saved.Workers
+	return true, nil
+}
+
+// validWorkers reports whether the ranges of ws, each running from the end of
+// the previous one to its End, tile [Start, End) in order with every Next
+// inside its range.
+func (cp *checkpoint) validWorkers(ws []checkpointWorker) bool {
+	lo := cp.Start
+	for _, w := range ws {
+		if w.End < lo || w.End > cp.End || w.Next < lo || w.Next > w.End {
+			return false
+		}
+		lo = w.End
+	}
+	return lo == cp.End
+}
+
+func (cp *checkpoint) sameProblem(o *checkpoint) bool {
+	if !bytes.Equal(cp.Plaintext, o.Plaintext) || !bytes.Equal(cp.Ciphertext, o.Ciphertext) || !bytes.Equal(cp.Tweak, o.Tweak) {
+		return false
+	}
+	if cp.Start != o.Start || cp.End != o.End || len(cp.Trcon) != len(o.Trcon) {
+		return false
+	}
+	for i := range cp.Trcon {
+		if cp.Trcon[i] != o.Trcon[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// search rebuilds the worker positions recorded in cp.
+func (cp *checkpoint) search() *search {
+	next := make([]uint64, len(cp.Workers))
+	end := make([]uint64, len(cp.Workers))
+	for i, w := range cp.Workers {
+		next[i], end[i] = w.Next, w.End
+	}
+	return resumeSearch(next, end, cp.End-cp.Start)
+}
+
+// record copies the current worker positions of s into cp.
+func (cp *checkpoint) record(s *search) {
+	next := s.positions()
+	cp.Workers = make([]checkpointWorker, len(next))
+	for i := range next {
+		cp.Workers[i] = checkpointWorker{Next: next[i], End: s.end[i]}
+	}
+}
+
+// save writes cp to path through a temporary file so a crash never leaves a
+// truncated checkpoint behind. The temporary file is removed if save fails.
+func (cp *checkpoint) save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
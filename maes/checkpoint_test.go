@@ -0,0 +1,176 @@
+package maes
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// checkpointProblem returns the pair used by TestGuess, whose key is found at
+// candidate 0xb594aee9.
+func checkpointProblem() (plaintext, ciphertext, tweak []byte, trcon []uint32, key []byte) {
+	plaintext = make([]byte, 16)
+	key = []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+		0x0e, 0x0f,
+	}
+	tweak = []byte("this is a tweak")
+	trcon = expandTrcon(tweak, 10)
+	c, err := New(key, tweak)
+	if err != nil {
+		panic(err)
+	}
+	ciphertext = make([]byte, 16)
+	c.Encrypt(ciphertext, plaintext, tweak)
+	return
+}
+
+func writeCheckpoint(t *testing.T, path string, cp *checkpoint) {
+	data, err := json.Marshal(cp)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func readCheckpoint(t *testing.T, path string) *checkpoint {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cp checkpoint
+	require.NoError(t, json.Unmarshal(data, &cp))
+	return &cp
+}
+
+func TestCheckpointSave(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := GuessKey(ctx, plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, 20*time.Millisecond))
+	a.ErrorIs(err, context.DeadlineExceeded)
+
+	cp := readCheckpoint(t, path)
+	a.Equal(ciphertext, cp.Ciphertext)
+	a.Equal(trcon, cp.Trcon)
+	a.Equal(uint64(0), cp.Start)
+	a.Equal(uint64(1<<32), cp.End)
+	a.Len(cp.Workers, guessWorkers)
+	tried := uint64(0)
+	for i, w := range cp.Workers {
+		a.Equal(uint64(i+1)<<28, w.End)
+		tried += w.Next - uint64(i)<<28
+	}
+	a.Greater(tried, uint64(0))
+}
+
+func TestCheckpointResume(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, 0, 1<<32)
+	cp.Workers = []checkpointWorker{
+		{Next: 0x10000000, End: 0x10000000},
+		{Next: 0xb594ae00, End: 1 << 32},
+	}
+	writeCheckpoint(t, path, cp)
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, time.Second))
+	a.NoError(err)
+	a.Equal(key, res)
+}
+
+func TestCheckpointExhausted(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, 0, 1<<32)
+	cp.Workers = []checkpointWorker{
+		{Next: 0x00000000, End: 0x00001000},
+		{Next: 0xb594af00, End: 0xb594b000},
+		{Next: 1 << 32, End: 1 << 32},
+	}
+	writeCheckpoint(t, path, cp)
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, time.Second))
+	a.Nil(res)
+	a.ErrorIs(err, ErrKeyNotFound)
+
+	cp = readCheckpoint(t, path)
+	for _, w := range cp.Workers {
+		a.Equal(w.End, w.Next)
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	cp := newCheckpoint(plaintext, ciphertext, []byte("another tweak"), trcon, 0, 1<<32)
+	writeCheckpoint(t, path, cp)
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, time.Second))
+	a.Nil(res)
+	a.ErrorIs(err, ErrCheckpointMismatch)
+}
+
+func TestCheckpointInvalid(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	tests := [][]checkpointWorker{
+		{},
+		// Next beyond End.
+		{{Next: 0x90000000, End: 0x80000000}, {Next: 0x80000000, End: 1 << 32}},
+		// Next before the end of the previous range.
+		{{Next: 0, End: 0x80000000}, {Next: 0x70000000, End: 1 << 32}},
+		// End outside the search range.
+		{{Next: 0, End: 0x80000000}, {Next: 0x80000000, End: 1<<32 + 1}},
+		// The last range stops short of End.
+		{{Next: 0, End: 0x80000000}, {Next: 0x80000000, End: 0xc0000000}},
+		// Ranges out of order.
+		{{Next: 0x80000000, End: 1 << 32}, {Next: 0, End: 0x80000000}},
+	}
+	for _, ws := range tests {
+		cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, 0, 1<<32)
+		cp.Workers = ws
+		writeCheckpoint(t, path, cp)
+		res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, time.Second))
+		a.Nil(res)
+		a.ErrorIs(err, ErrCheckpointInvalid, "%v", ws)
+	}
+}
+
+func TestCheckpointInterval(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	_, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithCheckpoint(path, 0))
+	a.EqualError(err, "maes: invalid checkpoint interval 0s")
+	_, err = os.Stat(path)
+	a.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestCheckpointSaveError(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	// Renaming a file over a directory fails after the temporary file is
+	// written.
+	path := filepath.Join(t.TempDir(), "search.json")
+	a.NoError(os.Mkdir(path, 0o755))
+	a.NoError(os.WriteFile(filepath.Join(path, "keep"), nil, 0o644))
+
+	cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, 0, 1<<32)
+	a.Error(cp.save(path))
+	_, err := os.Stat(path + ".tmp")
+	a.ErrorIs(err, os.ErrNotExist)
+}
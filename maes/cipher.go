@@ -0,0 +1,180 @@
+package maes
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/RainbowDashy/cipher/trace"
+	"golang.org/x/crypto/sha3"
+)
+
+// BlockSize is the maes block size in bytes.
+const BlockSize = 16
+
+// KeySizeError is returned by New when the key length is not supported.
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "maes: invalid key size " + strconv.Itoa(int(k))
+}
+
+// RoundsError is returned by NewReduced and NewReducedWithBackend when the
+// round count is not between 1 and 14.
+type RoundsError int
+
+func (r RoundsError) Error() string {
+	return "maes: invalid round count " + strconv.Itoa(int(r))
+}
+
+// TweakableBlock is a block cipher that takes a tweak alongside every block.
+type TweakableBlock interface {
+	// BlockSize returns the cipher's block size.
+	BlockSize() int
+
+	// Encrypt encrypts the first block in src into dst under tweak.
+	// Dst and src must overlap entirely or not at all.
+	Encrypt(dst, src, tweak []byte)
+
+	// Decrypt decrypts the first block in src into dst under tweak.
+	// Dst and src must overlap entirely or not at all.
+	Decrypt(dst, src, tweak []byte)
+}
+
+// Backend selects the implementation behind a Cipher.
+type Backend int
+
+const (
+	// Reference runs SubBytes, ShiftRows and MixColumns as separate steps.
+	Reference Backend = iota
+	// TTable merges the steps of each round into four table lookups per
+	// column.
+	TTable
+)
+
+// Cipher is a maes instance with a fixed key and round constants.
+type Cipher struct {
+	backend Backend
+	// finalMix keeps MixColumns in the last round of a reduced cipher.
+	finalMix bool
+	// tracer receives every step of the Reference path if set.
+	tracer trace.Tracer
+	wk     []uint32
+	trcon  []uint32
+}
+
+var _ TweakableBlock = (*Cipher)(nil)
+
+// New creates a Cipher from a 16-byte key using the Reference backend. The
+// tweak round constants are derived from trconSeed with SHAKE256.
+func New(key, trconSeed []byte) (*Cipher, error) {
+	return NewWithBackend(key, trconSeed, Reference)
+}
+
+// NewWithBackend is New with an explicit backend.
+func NewWithBackend(key, trconSeed []byte, backend Backend) (*Cipher, error) {
+	if len(key) != 16 {
+		return nil, KeySizeError(len(key))
+	}
+	return newCipher(key, trconSeed, 10, false, backend)
+}
+
+// NewReduced creates a Cipher that runs nr rounds, for nr from 1 to 14, using
+// the Reference backend. Rounds 0 to nr-2 replicate one AES-128 schedule word
+// each and the last two round keys keep the layout of rounds 9 and 10 of the
+// full cipher, so nr = 10 gives the cipher from New. The tweak schedule and
+// round constants are the first nr of the same SHAKE256 streams. If finalMix
+// is set the last round keeps MixColumns.
+func NewReduced(key, trconSeed []byte, nr int, finalMix bool) (*Cipher, error) {
+	return NewReducedWithBackend(key, trconSeed, nr, finalMix, Reference)
+}
+
+// NewReducedWithBackend is NewReduced with an explicit backend. Only
+// Reference supports finalMix.
+func NewReducedWithBackend(key, trconSeed []byte, nr int, finalMix bool, backend Backend) (*Cipher, error) {
+	if len(key) != 16 {
+		return nil, KeySizeError(len(key))
+	}
+	if nr < 1 || nr > 14 {
+		return nil, RoundsError(nr)
+	}
+	if finalMix && backend != Reference {
+		return nil, errors.New("maes: final MixColumns needs the Reference backend")
+	}
+	return newCipher(key, trconSeed, nr, finalMix, backend)
+}
+
+func newCipher(key, trconSeed []byte, nr int, finalMix bool, backend Backend) (*Cipher, error) {
+	if backend != Reference && backend != TTable {
+		return nil, errors.New("maes: unknown backend")
+	}
+	c := &Cipher{
+		backend:  backend,
+		finalMix: finalMix,
+		wk:       make([]uint32, 4*(nr+1)),
+		trcon:    expandTrcon(trconSeed, nr),
+	}
+	keyExpansion(key, c.wk)
+	return c, nil
+}
+
+// WithTracer returns a copy of c that reports every step of Encrypt and
+// Decrypt to tr. The copy always runs the Reference backend.
+func (c *Cipher) WithTracer(tr trace.Tracer) *Cipher {
+	t := *c
+	t.backend = Reference
+	t.tracer = tr
+	return &t
+}
+
+func (c *Cipher) BlockSize() int {
+	return BlockSize
+}
+
+func (c *Cipher) Encrypt(dst, src, tweak []byte) {
+	if len(src) < BlockSize {
+		panic("maes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("maes: output not full block")
+	}
+	var buf [56]uint32
+	wt := buf[:4*len(c.trcon)]
+	tweakExpansion(tweak, c.trcon, wt)
+	if c.backend == TTable {
+		encryptBlockTTable(c.wk, wt, dst, src)
+		return
+	}
+	encryptRounds(c.wk, wt, c.finalMix, c.tracer, dst, src)
+}
+
+func (c *Cipher) Decrypt(dst, src, tweak []byte) {
+	if len(src) < BlockSize {
+		panic("maes: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("maes: output not full block")
+	}
+	var buf [56]uint32
+	wt := buf[:4*len(c.trcon)]
+	tweakExpansion(tweak, c.trcon, wt)
+	if c.backend == TTable {
+		decryptBlockTTable(c.wk, wt, dst, src)
+		return
+	}
+	decryptRounds(c.wk, wt, c.finalMix, c.tracer, dst, src)
+}
+
+// expandTrcon derives n tweak round constants from seed. The full cipher uses
+// ten.
+func expandTrcon(seed []byte, n int) []uint32 {
+	trcon := make([]uint32, n)
+	rt := make([]byte, 4*len(trcon))
+	sh := sha3.NewShake256()
+	sh.Write(seed)
+	sh.Read(rt)
+	for i := range trcon {
+		trcon[i] = binary.BigEndian.Uint32(rt[4*i:])
+	}
+	return trcon
+}
@@ -0,0 +1,215 @@
+package maes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	seed := make([]byte, 16)
+	tweak := make([]byte, 15)
+	src := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(seed)
+	rg.Read(tweak)
+	rg.Read(src)
+
+	c, err := New(key, seed)
+	a.NoError(err)
+	a.Equal(BlockSize, c.BlockSize())
+
+	wk := make([]uint32, 44)
+	wt := make([]uint32, 40)
+	keyExpansion(key, wk)
+	tweakExpansion(tweak, expandTrcon(seed, 10), wt)
+	want := make([]byte, BlockSize)
+	encryptBlock(wk, wt, want, src)
+
+	dst := make([]byte, BlockSize)
+	c.Encrypt(dst, src, tweak)
+	a.Equal(want, dst)
+	c.Decrypt(dst, dst, tweak)
+	a.Equal(src, dst)
+
+	other := make([]byte, BlockSize)
+	c.Encrypt(other, src, []byte("another tweak"))
+	a.NotEqual(want, other)
+}
+
+func TestNewKeySize(t *testing.T) {
+	a := require.New(t)
+	for _, n := range []int{0, 15, 17, 24, 32} {
+		c, err := New(make([]byte, n), nil)
+		a.Nil(c)
+		a.Equal(KeySizeError(n), err)
+	}
+}
+
+func TestExpandTrcon(t *testing.T) {
+	a := require.New(t)
+	trcon := expandTrcon([]byte("this is a tweak"), 10)
+	a.Len(trcon, 10)
+	wt := make([]uint32, 40)
+	tweakExpansion([]byte("this is a tweak"), trcon, wt)
+	// The tests seed trcon with the tweak itself, so all four words of the
+	// first round tweak coincide.
+	a.Equal(wt[0], wt[2])
+}
+
+func TestNewReduced(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	seed := make([]byte, 16)
+	tweak := make([]byte, 15)
+	src := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(seed)
+	rg.Read(tweak)
+	rg.Read(src)
+
+	full, err := New(key, seed)
+	a.NoError(err)
+	c, err := NewReduced(key, seed, 10, false)
+	a.NoError(err)
+	a.Equal(full.wk, c.wk)
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	full.Encrypt(want, src, tweak)
+	c.Encrypt(dst, src, tweak)
+	a.Equal(want, dst)
+
+	for nr := 1; nr <= 14; nr++ {
+		plain, err := NewReduced(key, seed, nr, false)
+		a.NoError(err)
+		n := nr
+		if n > 10 {
+			n = 10
+		}
+		a.Equal(full.trcon[:n], plain.trcon[:n])
+		mixed, err := NewReduced(key, seed, nr, true)
+		a.NoError(err)
+
+		// Both variants differ only by MixColumns before the last key.
+		plain.Encrypt(want, src, tweak)
+		mixed.Encrypt(dst, src, tweak)
+		k := plain.wk[4*nr:]
+		var s, m [4]uint32
+		for i := range s {
+			s[i] = binary.BigEndian.Uint32(want[4*i:]) ^ k[i]
+			m[i] = binary.BigEndian.Uint32(dst[4*i:]) ^ k[i]
+		}
+		s[0], s[1], s[2], s[3] = mixColumns(s[0], s[1], s[2], s[3])
+		a.Equal(s, m)
+
+		plain.Decrypt(want, want, tweak)
+		a.Equal(src, want)
+		mixed.Decrypt(dst, dst, tweak)
+		a.Equal(src, dst)
+	}
+
+	_, err = NewReduced(key, seed, 0, false)
+	a.Equal(RoundsError(0), err)
+	_, err = NewReduced(key, seed, 15, true)
+	a.Equal(RoundsError(15), err)
+	_, err = NewReduced(key[:8], seed, 4, false)
+	a.Equal(KeySizeError(8), err)
+}
+
+func TestNewReducedWithBackend(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	seed := make([]byte, 16)
+	tweak := make([]byte, 15)
+	src := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(seed)
+	rg.Read(tweak)
+	rg.Read(src)
+
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	for nr := 1; nr <= 14; nr++ {
+		ref, err := NewReduced(key, seed, nr, false)
+		a.NoError(err)
+		tt, err := NewReducedWithBackend(key, seed, nr, false, TTable)
+		a.NoError(err)
+		ref.Encrypt(want, src, tweak)
+		tt.Encrypt(dst, src, tweak)
+		a.Equal(want, dst, "nr = %d", nr)
+		tt.Decrypt(dst, dst, tweak)
+		a.Equal(src, dst, "nr = %d", nr)
+	}
+
+	_, err := NewReducedWithBackend(key, seed, 4, true, TTable)
+	a.Error(err)
+	_, err = NewReducedWithBackend(key, seed, 4, false, Backend(7))
+	a.Error(err)
+	_, err = NewReducedWithBackend(key, seed, 15, false, TTable)
+	a.Equal(RoundsError(15), err)
+}
+
+func TestWithTracer(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	seed := make([]byte, 16)
+	tweak := make([]byte, 15)
+	src := make([]byte, BlockSize)
+	rg.Read(key)
+	rg.Read(seed)
+	rg.Read(tweak)
+	rg.Read(src)
+
+	var steps map[string][]byte
+	tr := trace.Func(func(round int, step string, state []byte) {
+		steps[fmt.Sprintf("%d.%s", round, step)] = append([]byte(nil), state...)
+	})
+
+	for _, backend := range []Backend{Reference, TTable} {
+		b, err := NewWithBackend(key, seed, backend)
+		a.NoError(err)
+		c := b.WithTracer(tr)
+		want := make([]byte, BlockSize)
+		dst := make([]byte, BlockSize)
+		b.Encrypt(want, src, tweak)
+
+		steps = map[string][]byte{}
+		c.Encrypt(dst, src, tweak)
+		a.Equal(want, dst)
+		a.Len(steps, 3+6*9+4+1)
+		a.Equal(src, steps["0.input"])
+		a.Equal(want, steps["10.output"])
+		wt := make([]uint32, 40)
+		tweakExpansion(tweak, c.trcon, wt)
+		for r := 0; r < 10; r++ {
+			var tw [16]byte
+			for i := 0; i < 4; i++ {
+				binary.BigEndian.PutUint32(tw[4*i:], wt[4*r+i])
+			}
+			a.Equal(tw[:], steps[fmt.Sprintf("%d.t_sch", r)])
+		}
+		enc := steps
+
+		// Round r of the inverse cipher walks back through round 11-r.
+		steps = map[string][]byte{}
+		c.Decrypt(dst, dst, tweak)
+		a.Equal(src, dst)
+		a.Len(steps, 2+6*9+6)
+		for r := 1; r <= 10; r++ {
+			a.Equal(enc[fmt.Sprintf("%d.s_row", 11-r)], steps[fmt.Sprintf("%d.istart", r)])
+			a.Equal(enc[fmt.Sprintf("%d.start", 11-r)], steps[fmt.Sprintf("%d.is_box", r)])
+			a.Equal(enc[fmt.Sprintf("%d.t_sch", 10-r)], steps[fmt.Sprintf("%d.it_sch", r)])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package maes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Job asks a worker to search [Start, End) for the key of one pair. Jobs and
+// Results travel as JSON lines, so any io.ReadWriter such as a net.Conn or a
+// child process's stdin and stdout can carry them.
+type Job struct {
+	Plaintext  []byte   `json:"plaintext"`
+	Ciphertext []byte   `json:"ciphertext"`
+	Tweak      []byte   `json:"tweak"`
+	Trcon      []uint32 `json:"trcon"`
+	Start      uint64   `json:"start"`
+	End        uint64   `json:"end"`
+}
+
+// Result answers the Job with the same range. Key is nil if the range holds
+// no match, and Err is set if the worker failed to search it.
+type Result struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+	Key   []byte `json:"key,omitempty"`
+	Err   string `json:"error,omitempty"`
+}
+
+// ServeWorker answers Jobs read from r with Results written to w until r
+// reaches EOF or ctx is done. Each Job runs through GuessKey with opts.
+func ServeWorker(ctx context.Context, r io.Reader, w io.Writer, opts ...Option) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	opts = append(opts[:len(opts):len(opts)], nil)
+	for {
+		var job Job
+		if err := dec.Decode(&job); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		res := Result{Start: job.Start, End: job.End}
+		opts[len(opts)-1] = WithRange(job.Start, job.End)
+		key, err := GuessKey(ctx, job.Plaintext, job.Ciphertext, job.Tweak, job.Trcon, opts...)
+		switch {
+		case err == nil:
+			res.Key = key
+		case errors.Is(err, ErrKeyNotFound):
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			res.Err = err.Error()
+		}
+		if err := enc.Encode(&res); err != nil {
+			return err
+		}
+	}
+}
+
+// Coordinate splits [start, end) into chunks of at most chunk candidates and
+// hands them out to the workers in conns, each served by ServeWorker, until
+// one reports the key. It returns ErrKeyNotFound once every chunk has been
+// searched, or the first worker error. conns must not be empty and start
+// must not exceed end. Jobs still in flight when Coordinate returns are
+// abandoned; close the connections to stop them.
+func Coordinate(ctx context.Context, plaintext, ciphertext, tweak []byte, trcon []uint32, start, end, chunk uint64, conns []io.ReadWriter) ([]byte, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("maes: no worker connections to coordinate")
+	}
+	if start > end {
+		return nil, fmt.Errorf("maes: invalid search range [%#x, %#x)", start, end)
+	}
+	if chunk == 0 {
+		chunk = end - start
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan Job)
+	go func() {
+		defer close(jobs)
+		for a := start; a < end; a += chunk {
+			job := Job{
+				Plaintext:  plaintext,
+				Ciphertext: ciphertext,
+				Tweak:      tweak,
+				Trcon:      trcon,
+				Start:      a,
+				End:        a + chunk,
+			}
+			if job.End > end || job.End < a {
+				job.End = end
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Buffered so that workers finishing after Coordinate returns never block.
+	keys := make(chan []byte, len(conns))
+	errs := make(chan error, len(conns))
+	for _, conn := range conns {
+		go func(conn io.ReadWriter) {
+			errs <- drive(ctx, conn, jobs, keys)
+		}(conn)
+	}
+
+	for range conns {
+		select {
+		case key := <-keys:
+			return key, nil
+		case err := <-errs:
+			if err != nil {
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	select {
+	case key := <-keys:
+		return key, nil
+	default:
+	}
+	return nil, ErrKeyNotFound
+}
+
+// drive feeds jobs to one worker connection until jobs runs out, the worker
+// finds the key or fails, or ctx is done.
+func drive(ctx context.Context, conn io.ReadWriter, jobs <-chan Job, keys chan<- []byte) error {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	for job := range jobs {
+		if err := enc.Encode(&job); err != nil {
+			return err
+		}
+		var res Result
+		if err := dec.Decode(&res); err != nil {
+			return err
+		}
+		if res.Err != "" {
+			return errors.New(res.Err)
+		}
+		if res.Key != nil {
+			keys <- res.Key
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Worker is a local worker process speaking the Job/Result protocol over its
+// stdin and stdout.
+type Worker struct {
+	cmd *exec.Cmd
+	io.Reader
+	io.WriteCloser
+}
+
+// StartWorker runs name with args as a worker process. The process is killed
+// when ctx is done.
+func StartWorker(ctx context.Context, name string, args ...string) (*Worker, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Worker{cmd: cmd, Reader: stdout, WriteCloser: stdin}, nil
+}
+
+// Close closes the worker's stdin, which ends ServeWorker, and waits for the
+// process to exit.
+func (w *Worker) Close() error {
+	w.WriteCloser.Close()
+	return w.cmd.Wait()
+}
@@ -0,0 +1,114 @@
+package maes
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// servePipes starts n in-process workers and returns the coordinator ends of
+// their connections. The workers are stopped and waited for on cleanup.
+func servePipes(t *testing.T, n int) []io.ReadWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	conns := make([]io.ReadWriter, n)
+	for i := range conns {
+		c, s := net.Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.Close()
+			ServeWorker(ctx, s, s, WithWorkers(2))
+		}()
+		conns[i] = c
+	}
+	t.Cleanup(func() {
+		cancel()
+		for _, c := range conns {
+			c.(net.Conn).Close()
+		}
+		wg.Wait()
+	})
+	return conns
+}
+
+func TestCoordinate(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+	res, err := Coordinate(context.Background(), plaintext, ciphertext, tweak, trcon, 0xb5940000, 0xb5950000, 0x4000, servePipes(t, 3))
+	a.NoError(err)
+	a.Equal(key, res)
+}
+
+func TestCoordinateNotFound(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	res, err := Coordinate(context.Background(), plaintext, ciphertext, tweak, trcon, 0, 0x3000, 0x1000, servePipes(t, 2))
+	a.Nil(res)
+	a.ErrorIs(err, ErrKeyNotFound)
+}
+
+func TestCoordinateWorkerError(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	res, err := Coordinate(context.Background(), plaintext, ciphertext, tweak, trcon, 0, 1<<33, 1<<33, servePipes(t, 1))
+	a.Nil(res)
+	a.ErrorContains(err, "invalid search range")
+}
+
+func TestCoordinateInvalid(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	res, err := Coordinate(context.Background(), plaintext, ciphertext, tweak, trcon, 0, 0x3000, 0x1000, nil)
+	a.Nil(res)
+	a.EqualError(err, "maes: no worker connections to coordinate")
+	a.NotErrorIs(err, ErrKeyNotFound)
+
+	res, err = Coordinate(context.Background(), plaintext, ciphertext, tweak, trcon, 0x3000, 0x1000, 0x1000, servePipes(t, 1))
+	a.Nil(res)
+	a.EqualError(err, "maes: invalid search range [0x3000, 0x1000)")
+}
+
+func TestHelperWorker(t *testing.T) {
+	if os.Getenv("MAES_HELPER_WORKER") != "1" {
+		return
+	}
+	err := ServeWorker(context.Background(), os.Stdin, os.Stdout, WithWorkers(2))
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestStartWorker(t *testing.T) {
+	a := require.New(t)
+	t.Setenv("MAES_HELPER_WORKER", "1")
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+	// os/exec watches ctx from a goroutine that ends shortly after Wait.
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		for i := 0; i < 1000 && runtime.NumGoroutine() > before; i++ {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conns := make([]io.ReadWriter, 2)
+	for i := range conns {
+		w, err := StartWorker(ctx, os.Args[0], "-test.run=^TestHelperWorker$")
+		a.NoError(err)
+		defer w.Close()
+		conns[i] = w
+	}
+	res, err := Coordinate(ctx, plaintext, ciphertext, tweak, trcon, 0xb5940000, 0xb5950000, 0x4000, conns)
+	a.NoError(err)
+	a.Equal(key, res)
+}
@@ -4,78 +4,403 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
-	"fmt"
+	"errors"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RainbowDashy/cipher/gf256"
 )
 
-func guessKey(plaintext, ciphertext, tweak []byte, trcon []uint32) []byte {
-	ctx, cancel := context.WithCancel(context.Background())
-	resChan := make(chan []byte, 1)
-	for i := 0; i < 16; i++ {
-		go guessPart(ctx, resChan, plaintext, ciphertext, tweak, trcon, i)
+// ErrKeyNotFound is returned by GuessKey when no candidate in the search space
+// reproduces the ciphertext.
+var ErrKeyNotFound = errors.New("maes: key not found")
+
+const guessWorkers = 16
+
+// GuessKey recovers the key that encrypts plaintext to ciphertext under tweak
+// and trcon by searching all 2^32 candidates, or the range given by
+// WithRange. It returns ctx.Err() if ctx is done before the search finishes
+// and ErrKeyNotFound if the range is exhausted. All workers have exited by
+// the time GuessKey returns.
+func GuessKey(ctx context.Context, plaintext, ciphertext, tweak []byte, trcon []uint32, opts ...Option) ([]byte, error) {
+	cfg, err := newGuessConfig(opts)
+	if err != nil {
+		return nil, err
 	}
-	key := <-resChan
-	cancel()
-	close(resChan)
-	return key
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newSearch(cfg.start, cfg.end, cfg.workers)
+	if cfg.checkpointPath != "" {
+		cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, cfg.start, cfg.end)
+		ok, err := cp.load(cfg.checkpointPath)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s = cp.search()
+		}
+	}
+
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+		stops = nil
+	}
+	defer stopAll()
+	if cfg.progress != nil {
+		stops = append(stops, tick(cfg.progressInterval, func() {
+			cfg.progress(s.progress())
+		}))
+	}
+	var saveErr error
+	if cfg.checkpointPath != "" {
+		cp := newCheckpoint(plaintext, ciphertext, tweak, trcon, cfg.start, cfg.end)
+		save := func() error {
+			cp.record(s)
+			return cp.save(cfg.checkpointPath)
+		}
+		if err := save(); err != nil {
+			return nil, err
+		}
+		stops = append(stops, tick(cfg.checkpointInterval, func() {
+			if err := save(); err != nil && saveErr == nil {
+				saveErr = err
+			}
+		}))
+	}
+
+	// Every worker can send without blocking, so simultaneous finds never
+	// strand a goroutine.
+	resChan := make(chan []byte, len(s.next))
+	var wg sync.WaitGroup
+	for i := range s.next {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			g := newGuesser(plaintext, ciphertext, tweak, trcon)
+			g.confirm = cfg.confirm
+			if cfg.engine == BitslicedEngine {
+				guessRangeBitsliced(ctx, resChan, g, &s.next[idx], s.end[idx])
+			} else {
+				guessRange(ctx, resChan, g, &s.next[idx], s.end[idx])
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case key := <-resChan:
+		cancel()
+		<-done
+		return key, nil
+	case <-done:
+	}
+	select {
+	case key := <-resChan:
+		return key, nil
+	default:
+	}
+	// Flush the final positions before deciding what to report.
+	stopAll()
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrKeyNotFound
 }
 
-func guessPart(ctx context.Context, resChan chan<- []byte, plaintext, ciphertext, tweak []byte, trcon []uint32, idx int) {
-	a0 := uint32(idx << 28)
-	a1 := uint32(0)
-	for {
-		if ctx.Err() != nil {
+// search tracks the position of every worker. Worker i tests the candidates
+// in [next[i], end[i]) and advances next[i] atomically as it goes.
+type search struct {
+	begin []uint64
+	next  []uint64
+	end   []uint64
+	space uint64
+	start time.Time
+}
+
+// newSearch splits the candidates in [start, end) evenly between workers.
+func newSearch(start, end uint64, workers int) *search {
+	space := end - start
+	if uint64(workers) > space {
+		workers = int(space)
+	}
+	next := make([]uint64, workers)
+	ends := make([]uint64, workers)
+	for i := range next {
+		next[i] = start + space*uint64(i)/uint64(workers)
+		ends[i] = start + space*uint64(i+1)/uint64(workers)
+	}
+	return resumeSearch(next, ends, space)
+}
+
+// resumeSearch continues a search whose workers have reached next.
+func resumeSearch(next, end []uint64, space uint64) *search {
+	s := &search{
+		begin: make([]uint64, len(next)),
+		next:  make([]uint64, len(next)),
+		end:   make([]uint64, len(end)),
+		space: space,
+		start: time.Now(),
+	}
+	copy(s.begin, next)
+	copy(s.next, next)
+	copy(s.end, end)
+	return s
+}
+
+// positions returns a consistent-enough copy of every worker's position.
+func (s *search) positions() []uint64 {
+	next := make([]uint64, len(s.next))
+	for i := range next {
+		next[i] = atomic.LoadUint64(&s.next[i])
+	}
+	return next
+}
+
+// tick calls fn every interval from a new goroutine. The returned function
+// stops the ticker, calls fn one last time and waits for it to return.
+func tick(interval time.Duration, fn func()) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-stop:
+				fn()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// guessRange tests the candidates in [*next, end) with g, publishing its
+// position in *next every progressFlush candidates and when it returns. ctx is
+// only checked at the start and at every such flush. If it finds the key,
+// *next is left at the matching candidate.
+func guessRange(ctx context.Context, resChan chan<- []byte, g *guesser, next *uint64, end uint64) {
+	a := atomic.LoadUint64(next)
+	defer func() {
+		atomic.StoreUint64(next, a)
+	}()
+	n := 0
+	for ; a < end; a++ {
+		if n == 0 && ctx.Err() != nil {
 			return
 		}
-		a := a0 | a1
-		key := guess(plaintext, ciphertext, tweak, trcon, a)
-		if key != nil {
-			resChan <- key
+		if g.guess(uint32(a)) {
+			resChan <- g.key()
 			return
 		}
-		a1++
-		if a1&0xf0000000 != 0 {
-			break
+		n++
+		if n == progressFlush {
+			atomic.StoreUint64(next, a+1)
+			n = 0
 		}
+	}
+}
 
-		if a1%0xfffffff == 0 {
-			fmt.Printf("idx %d: finishing %d\n", idx, a1/0xfffffff)
+// guessRangeBitsliced is guessRange using a bsGuesser for every aligned run
+// of 64 candidates.
+func guessRangeBitsliced(ctx context.Context, resChan chan<- []byte, g *guesser, next *uint64, end uint64) {
+	bg := newBsGuesser(g)
+	a := atomic.LoadUint64(next)
+	defer func() {
+		atomic.StoreUint64(next, a)
+	}()
+	n := 0
+	for a < end {
+		if n == 0 && ctx.Err() != nil {
+			return
+		}
+		if a%64 != 0 || end-a < 64 {
+			if g.guess(uint32(a)) {
+				resChan <- g.key()
+				return
+			}
+			a++
+			n++
+		} else {
+			for lanes := bg.filter(uint32(a)); lanes != 0; lanes &= lanes - 1 {
+				c := a + uint64(bits.TrailingZeros64(lanes))
+				if g.guess(uint32(c)) {
+					a = c
+					resChan <- g.key()
+					return
+				}
+			}
+			a += 64
+			n += 64
+		}
+		if n >= progressFlush {
+			atomic.StoreUint64(next, a)
+			n = 0
 		}
 	}
 }
 
+// guess tests candidate a for a single pair and returns the key or nil.
 func guess(plaintext, ciphertext, tweak []byte, trcon []uint32, a uint32) []byte {
+	g := newGuesser(plaintext, ciphertext, tweak, trcon)
+	if g.guess(a) {
+		return g.key()
+	}
+	return nil
+}
+
+// guesser tests candidates against one plaintext/ciphertext pair. The tweak
+// schedule is expanded once and the per-candidate buffers are reused, so
+// guess does not allocate. A guesser must not be shared between goroutines.
+type guesser struct {
+	plaintext  []byte
+	ciphertext []byte
+	// confirm holds further pairs a key must also satisfy.
+	confirm [][2][]byte
+	// uniform is set when every column of the state is equal after each of
+	// the first nine rounds whatever the key, so one column stands for the
+	// whole state there.
+	uniform   bool
+	w         [13]uint32
+	wk        [44]uint32
+	wt        [40]uint32
+	encrypted [16]byte
+}
+
+func newGuesser(plaintext, ciphertext, tweak []byte, trcon []uint32) *guesser {
+	g := &guesser{
+		plaintext:  plaintext,
+		ciphertext: ciphertext,
+	}
+	tweakExpansion(tweak, trcon, g.wt[:])
+
+	p0 := binary.BigEndian.Uint32(plaintext[0:4]) ^ g.wt[0]
+	g.uniform = p0 == binary.BigEndian.Uint32(plaintext[4:8])^g.wt[1] &&
+		p0 == binary.BigEndian.Uint32(plaintext[8:12])^g.wt[2] &&
+		p0 == binary.BigEndian.Uint32(plaintext[12:16])^g.wt[3]
+	for i := 4; i < 36; i += 4 {
+		g.uniform = g.uniform && g.wt[i] == g.wt[i+2]
+	}
+	return g
+}
+
+// guess reports whether candidate a recovers the key. On success the key is
+// available from g.key until the next call.
+//
+// Candidates are checked in stages. The final round keys are derived from a
+// and the ciphertext, so decrypting the last rounds agrees with the
+// ciphertext for every candidate and cannot reject any on its own; the check
+// has to reach the plaintext. Undoing the last two rounds is cheap, because
+// the state entering them is fixed by a, and gives the first column the state
+// must have after round 8. Encrypting the plaintext through round 8 on the
+// T-tables, the main cost per candidate, and comparing that column rejects
+// all but about 2^-32 of the wrong candidates. Only the survivors are
+// encrypted in full and checked against the confirmation pairs.
+func (g *guesser) guess(a uint32) bool {
 	a0, a1, a2, a3 := byte(a>>24), byte(a>>16&0xff), byte(a>>8&0xff), byte(a&0xff)
-	wk := make([]uint32, 13)
-	wk[9] = uint32(a0^sbox1[ciphertext[0]])<<24 | uint32(a1^sbox1[ciphertext[13]])<<16 | uint32(a2^sbox1[ciphertext[10]])<<8 | uint32(a3^sbox1[ciphertext[7]])
-	wk[10] = uint32(a0^sbox1[ciphertext[4]])<<24 | uint32(a1^sbox1[ciphertext[1]])<<16 | uint32(a2^sbox1[ciphertext[14]])<<8 | uint32(a3^sbox1[ciphertext[11]])
-	wk[11] = uint32(sbox0[a0]^ciphertext[8])<<24 | uint32(sbox0[a1]^ciphertext[5])<<16 | uint32(sbox0[a2]^ciphertext[2])<<8 | uint32(sbox0[a3]^ciphertext[15])
-	wk[12] = uint32(sbox0[a0]^ciphertext[12])<<24 | uint32(sbox0[a1]^ciphertext[9])<<16 | uint32(sbox0[a2]^ciphertext[6])<<8 | uint32(sbox0[a3]^ciphertext[3])
+	ciphertext := g.ciphertext
+	w := &g.w
+	w[9] = uint32(a0^gf256.AESInvSBox[ciphertext[0]])<<24 | uint32(a1^gf256.AESInvSBox[ciphertext[13]])<<16 | uint32(a2^gf256.AESInvSBox[ciphertext[10]])<<8 | uint32(a3^gf256.AESInvSBox[ciphertext[7]])
+	w[10] = uint32(a0^gf256.AESInvSBox[ciphertext[4]])<<24 | uint32(a1^gf256.AESInvSBox[ciphertext[1]])<<16 | uint32(a2^gf256.AESInvSBox[ciphertext[14]])<<8 | uint32(a3^gf256.AESInvSBox[ciphertext[11]])
+	w[11] = uint32(gf256.AESSBox[a0]^ciphertext[8])<<24 | uint32(gf256.AESSBox[a1]^ciphertext[5])<<16 | uint32(gf256.AESSBox[a2]^ciphertext[2])<<8 | uint32(gf256.AESSBox[a3]^ciphertext[15])
+	w[12] = uint32(gf256.AESSBox[a0]^ciphertext[12])<<24 | uint32(gf256.AESSBox[a1]^ciphertext[9])<<16 | uint32(gf256.AESSBox[a2]^ciphertext[6])<<8 | uint32(gf256.AESSBox[a3]^ciphertext[3])
 	for i := 8; i >= 0; i-- {
 		if i%4 == 0 {
-			wk[i] = wk[i+4] ^ subw(rotw(wk[i+3])) ^ rcon[(i+4)/4]
+			w[i] = w[i+4] ^ subw(rotw(w[i+3])) ^ uint32(gf256.AESRcon[(i+4)/4])<<24
 		} else {
-			wk[i] = wk[i+4] ^ wk[i+3]
+			w[i] = w[i+4] ^ w[i+3]
 		}
 	}
 
-	key := make([]byte, 16)
-	binary.BigEndian.PutUint32(key[0:4], wk[0])
-	binary.BigEndian.PutUint32(key[4:8], wk[1])
-	binary.BigEndian.PutUint32(key[8:12], wk[2])
-	binary.BigEndian.PutUint32(key[12:16], wk[3])
+	want := g.lastRoundsColumn(a)
+	if g.round8Column(a) != want {
+		return false
+	}
 
-	if checkKey(plaintext, ciphertext, tweak, trcon, key) {
-		return key
+	// w now holds the first 13 words of the AES-128 schedule for the
+	// candidate key, which is all keyExpansion needs.
+	expandWords(w[:], g.wk[:])
+	encryptBlock(g.wk[:], g.wt[:], g.encrypted[:], g.plaintext)
+	if !bytes.Equal(g.encrypted[:], ciphertext) {
+		return false
 	}
-	return nil
+	for _, pair := range g.confirm {
+		encryptBlock(g.wk[:], g.wt[:], g.encrypted[:], pair[0])
+		if !bytes.Equal(g.encrypted[:], pair[1]) {
+			return false
+		}
+	}
+	return true
 }
 
-func checkKey(plaintext, ciphertext, tweak []byte, trcon []uint32, key []byte) bool {
-	wk := make([]uint32, 44)
-	wt := make([]uint32, 40)
-	encrypted := make([]byte, len(plaintext))
-	keyExpansion(key, wk)
-	tweakExpansion(tweak, trcon, wt)
-	encryptBlock(wk, wt, encrypted, plaintext)
-	return bytes.Equal(encrypted, ciphertext)
+// lastRoundsColumn undoes rounds 10 and 9 for candidate a and returns the
+// first column of the state after round 8. By construction of w[9..12] the
+// state entering the last round is (x0, x1, a, a) with x0^w[9] = x1^w[10] = a,
+// so after removing the round 9 key its columns are a^trcon[9] twice and
+// a^rt[9] twice.
+func (g *guesser) lastRoundsColumn(a uint32) uint32 {
+	u0 := invMixColumn(a ^ g.wt[36])
+	u2 := invMixColumn(a ^ g.wt[38])
+	// u1 = u0 and u3 = u2; InvShiftRows takes row r of column 0 from
+	// column -r.
+	t := u0&0xff000000 | u2&0x00ff0000 | u2&0x0000ff00 | u0&0x000000ff
+	return uint32(gf256.AESInvSBox[t>>24])<<24 | uint32(gf256.AESInvSBox[t>>16&0xff])<<16 | uint32(gf256.AESInvSBox[t>>8&0xff])<<8 | uint32(gf256.AESInvSBox[t&0xff])
+}
+
+// round8Column encrypts the plaintext through round 8 under the schedule in
+// g.w and returns the first column of the state. Every one of these rounds
+// keeps MixColumns, so it runs on the T-tables.
+func (g *guesser) round8Column(a uint32) uint32 {
+	w := &g.w
+	wt := &g.wt
+	s0 := binary.BigEndian.Uint32(g.plaintext[0:4]) ^ w[0] ^ wt[0]
+	if g.uniform {
+		// ShiftRows is the identity on a state with equal columns.
+		for r := 1; r < 9; r++ {
+			s0 = gf256.AESTe0[s0>>24] ^ gf256.AESTe1[s0>>16&0xff] ^ gf256.AESTe2[s0>>8&0xff] ^ gf256.AESTe3[s0&0xff] ^ w[r] ^ wt[4*r]
+		}
+		return s0
+	}
+	s1 := binary.BigEndian.Uint32(g.plaintext[4:8]) ^ w[0] ^ wt[1]
+	s2 := binary.BigEndian.Uint32(g.plaintext[8:12]) ^ w[0] ^ wt[2]
+	s3 := binary.BigEndian.Uint32(g.plaintext[12:16]) ^ w[0] ^ wt[3]
+	for r := 1; r < 8; r++ {
+		k := 4 * r
+		t0 := gf256.AESTe0[s0>>24] ^ gf256.AESTe1[s1>>16&0xff] ^ gf256.AESTe2[s2>>8&0xff] ^ gf256.AESTe3[s3&0xff] ^ w[r] ^ wt[k+0]
+		t1 := gf256.AESTe0[s1>>24] ^ gf256.AESTe1[s2>>16&0xff] ^ gf256.AESTe2[s3>>8&0xff] ^ gf256.AESTe3[s0&0xff] ^ w[r] ^ wt[k+1]
+		t2 := gf256.AESTe0[s2>>24] ^ gf256.AESTe1[s3>>16&0xff] ^ gf256.AESTe2[s0>>8&0xff] ^ gf256.AESTe3[s1&0xff] ^ w[r] ^ wt[k+2]
+		t3 := gf256.AESTe0[s3>>24] ^ gf256.AESTe1[s0>>16&0xff] ^ gf256.AESTe2[s1>>8&0xff] ^ gf256.AESTe3[s2&0xff] ^ w[r] ^ wt[k+3]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+	// Round 8 only needs column 0.
+	return gf256.AESTe0[s0>>24] ^ gf256.AESTe1[s1>>16&0xff] ^ gf256.AESTe2[s2>>8&0xff] ^ gf256.AESTe3[s3&0xff] ^ w[8] ^ wt[32]
+}
+
+// key returns a copy of the key found by the last successful guess.
+func (g *guesser) key() []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key[0:4], g.w[0])
+	binary.BigEndian.PutUint32(key[4:8], g.w[1])
+	binary.BigEndian.PutUint32(key[8:12], g.w[2])
+	binary.BigEndian.PutUint32(key[12:16], g.w[3])
+	return key
 }
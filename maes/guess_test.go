@@ -1,11 +1,14 @@
 package maes
 
 import (
+	"context"
 	"encoding/binary"
+	"math/rand"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
-	"golang.org/x/crypto/sha3"
 )
 
 func TestGuess(t *testing.T) {
@@ -19,15 +22,8 @@ func TestGuess(t *testing.T) {
 		0x0e, 0x0f,
 	}
 
-	trcon := make([]uint32, 10)
 	tweak := []byte("this is a tweak")
-	rt := make([]byte, 4*len(trcon))
-	sh := sha3.NewShake256()
-	sh.Write(tweak)
-	sh.Read(rt)
-	for i := range trcon {
-		trcon[i] = binary.BigEndian.Uint32(rt[4*i:])
-	}
+	trcon := expandTrcon(tweak, 10)
 	wk := make([]uint32, 44)
 	wt := make([]uint32, 40)
 	keyExpansion(key, wk)
@@ -50,6 +46,9 @@ func TestGuess(t *testing.T) {
 }
 
 func TestGuessKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("full key search")
+	}
 	a := require.New(t)
 	plaintext := []byte{
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
@@ -60,15 +59,8 @@ func TestGuessKey(t *testing.T) {
 		0x0e, 0x0f,
 	}
 
-	trcon := make([]uint32, 10)
 	tweak := []byte("this is a tweak")
-	rt := make([]byte, 4*len(trcon))
-	sh := sha3.NewShake256()
-	sh.Write(tweak)
-	sh.Read(rt)
-	for i := range trcon {
-		trcon[i] = binary.BigEndian.Uint32(rt[4*i:])
-	}
+	trcon := expandTrcon(tweak, 10)
 	wk := make([]uint32, 44)
 	wt := make([]uint32, 40)
 	keyExpansion(key, wk)
@@ -85,10 +77,145 @@ func TestGuessKey(t *testing.T) {
 	decrptyBlock(wk, wt, decrypted, encrypted)
 	a.Equal(plaintext, decrypted)
 
-	res := guessKey(plaintext, encrypted, tweak, trcon)
+	res, err := GuessKey(context.Background(), plaintext, encrypted, tweak, trcon)
+	a.NoError(err)
 	a.Equal(res, key)
 }
 
+func TestGuessKeyCancel(t *testing.T) {
+	a := require.New(t)
+	plaintext := make([]byte, 16)
+	tweak := []byte("this is a tweak")
+	trcon := expandTrcon(tweak, 10)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err := GuessKey(ctx, plaintext, plaintext, tweak, trcon)
+	a.Nil(res)
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Equal(before, runtime.NumGoroutine())
+}
+
+func TestGuessKeyProgress(t *testing.T) {
+	a := require.New(t)
+	plaintext := make([]byte, 16)
+	tweak := []byte("this is a tweak")
+	trcon := expandTrcon(tweak, 10)
+
+	var reports []Progress
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := GuessKey(ctx, plaintext, plaintext, tweak, trcon, WithProgress(20*time.Millisecond, func(p Progress) {
+		reports = append(reports, p)
+	}))
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Greater(len(reports), 1)
+
+	last := reports[len(reports)-1]
+	a.Len(last.Tried, guessWorkers)
+	a.Equal(uint64(1<<32), last.Space)
+	a.Greater(last.Total, uint64(0))
+	a.Greater(last.Rate, float64(0))
+	a.Greater(last.Remaining, time.Duration(0))
+	sum := uint64(0)
+	for _, n := range last.Tried {
+		sum += n
+	}
+	a.Equal(last.Total, sum)
+}
+
+func TestGuessKeyRange(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb5940000, 0xb5950000), WithWorkers(3))
+	a.NoError(err)
+	a.Equal(key, res)
+
+	res, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb594aeea, 0xb594af00), WithWorkers(64))
+	a.Nil(res)
+	a.ErrorIs(err, ErrKeyNotFound)
+
+	_, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(5, 5))
+	a.Error(err)
+	_, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0, 1<<32+1))
+	a.Error(err)
+	_, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithWorkers(0))
+	a.Error(err)
+	_, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithProgress(0, func(Progress) {}))
+	a.EqualError(err, "maes: invalid progress interval 0s")
+}
+
+func TestGuesserStages(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	random := make([]byte, 16)
+	rg.Read(random)
+
+	for _, g := range []*guesser{
+		newGuesser(plaintext, ciphertext, tweak, trcon),
+		newGuesser(random, ciphertext, tweak, trcon),
+	} {
+		for i := 0; i < 100; i++ {
+			c := rg.Uint32()
+			g.guess(c)
+			expandWords(g.w[:], g.wk[:])
+			wk, wt := g.wk[:], g.wt[:]
+
+			s0 := binary.BigEndian.Uint32(g.plaintext[0:4]) ^ wk[0] ^ wt[0]
+			s1 := binary.BigEndian.Uint32(g.plaintext[4:8]) ^ wk[1] ^ wt[1]
+			s2 := binary.BigEndian.Uint32(g.plaintext[8:12]) ^ wk[2] ^ wt[2]
+			s3 := binary.BigEndian.Uint32(g.plaintext[12:16]) ^ wk[3] ^ wt[3]
+			for k := 4; k < 36; k += 4 {
+				s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+				s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
+				s0, s1, s2, s3 = mixColumns(s0, s1, s2, s3)
+				s0 ^= wk[k+0] ^ wt[k+0]
+				s1 ^= wk[k+1] ^ wt[k+1]
+				s2 ^= wk[k+2] ^ wt[k+2]
+				s3 ^= wk[k+3] ^ wt[k+3]
+			}
+			a.Equal(s0, g.round8Column(c))
+
+			s0 = binary.BigEndian.Uint32(ciphertext[0:4]) ^ wk[40]
+			s1 = binary.BigEndian.Uint32(ciphertext[4:8]) ^ wk[41]
+			s2 = binary.BigEndian.Uint32(ciphertext[8:12]) ^ wk[42]
+			s3 = binary.BigEndian.Uint32(ciphertext[12:16]) ^ wk[43]
+			s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+			s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+			s0 ^= wk[36] ^ wt[36]
+			s1 ^= wk[37] ^ wt[37]
+			s2 ^= wk[38] ^ wt[38]
+			s3 ^= wk[39] ^ wt[39]
+			s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
+			s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+			s0, _, _, _ = invSubBytes(s0, s1, s2, s3)
+			a.Equal(s0, g.lastRoundsColumn(c))
+		}
+	}
+}
+
+func TestGuessKeyConfirm(t *testing.T) {
+	a := require.New(t)
+	plaintext, ciphertext, tweak, trcon, key := checkpointProblem()
+	c, err := New(key, tweak)
+	a.NoError(err)
+	plaintext2 := []byte("second plaintext")
+	ciphertext2 := make([]byte, 16)
+	c.Encrypt(ciphertext2, plaintext2, tweak)
+
+	res, err := GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb594a000, 0xb594b000), WithConfirm(plaintext2, ciphertext2))
+	a.NoError(err)
+	a.Equal(key, res)
+
+	ciphertext2[0] ^= 1
+	res, err = GuessKey(context.Background(), plaintext, ciphertext, tweak, trcon, WithRange(0xb594a000, 0xb594b000), WithConfirm(plaintext2, ciphertext2))
+	a.Nil(res)
+	a.ErrorIs(err, ErrKeyNotFound)
+}
+
 func BenchmarkGuess(b *testing.B) {
 	plaintext := []byte{
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
@@ -99,15 +226,8 @@ func BenchmarkGuess(b *testing.B) {
 		0x0e, 0x0f,
 	}
 
-	trcon := make([]uint32, 10)
 	tweak := []byte("this is a tweak")
-	rt := make([]byte, 4*len(trcon))
-	sh := sha3.NewShake256()
-	sh.Write(tweak)
-	sh.Read(rt)
-	for i := range trcon {
-		trcon[i] = binary.BigEndian.Uint32(rt[4*i:])
-	}
+	trcon := expandTrcon(tweak, 10)
 	wk := make([]uint32, 44)
 	wt := make([]uint32, 40)
 	keyExpansion(key, wk)
@@ -123,3 +243,19 @@ func BenchmarkGuess(b *testing.B) {
 		_ = guess(plaintext, encrypted, tweak, trcon, uint32(i))
 	}
 }
+
+func BenchmarkGuesser(b *testing.B) {
+	plaintext, ciphertext, tweak, trcon, _ := checkpointProblem()
+	g := newGuesser(plaintext, ciphertext, tweak, trcon)
+	if allocs := testing.AllocsPerRun(100, func() {
+		g.guess(0x12345678)
+	}); allocs != 0 {
+		b.Fatalf("guess allocates %v times per candidate", allocs)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = g.guess(uint32(i))
+	}
+}
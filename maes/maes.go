@@ -3,26 +3,48 @@ package maes
 import (
 	"encoding/binary"
 
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/RainbowDashy/cipher/trace"
 	"golang.org/x/crypto/sha3"
 )
 
 func encryptBlock(wk, wt []uint32, dst, src []byte) {
+	encryptRounds(wk, wt, false, nil, dst, src)
+}
+
+func decrptyBlock(wk, wt []uint32, dst, src []byte) {
+	decryptRounds(wk, wt, false, nil, dst, src)
+}
+
+// encryptRounds runs len(wk)/4-1 rounds, using a tweak word from wt in all
+// but the last. The last round keeps MixColumns if finalMix is set. Every
+// step is reported to tr unless it is nil.
+func encryptRounds(wk, wt []uint32, finalMix bool, tr trace.Tracer, dst, src []byte) {
 	s0 := binary.BigEndian.Uint32(src[0:4])
 	s1 := binary.BigEndian.Uint32(src[4:8])
 	s2 := binary.BigEndian.Uint32(src[8:12])
 	s3 := binary.BigEndian.Uint32(src[12:16])
 
+	traceState(tr, 0, "input", s0, s1, s2, s3)
+	traceState(tr, 0, "k_sch", wk[0], wk[1], wk[2], wk[3])
+	traceState(tr, 0, "t_sch", wt[0], wt[1], wt[2], wt[3])
 	s0 ^= wk[0] ^ wt[0]
 	s1 ^= wk[1] ^ wt[1]
 	s2 ^= wk[2] ^ wt[2]
 	s3 ^= wk[3] ^ wt[3]
 
-	nr := 10
+	nr := len(wk)/4 - 1
 	k := 4
 	for r := 1; r < nr; r++ {
+		traceState(tr, r, "start", s0, s1, s2, s3)
 		s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+		traceState(tr, r, "s_box", s0, s1, s2, s3)
 		s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
+		traceState(tr, r, "s_row", s0, s1, s2, s3)
 		s0, s1, s2, s3 = mixColumns(s0, s1, s2, s3)
+		traceState(tr, r, "m_col", s0, s1, s2, s3)
+		traceState(tr, r, "k_sch", wk[k+0], wk[k+1], wk[k+2], wk[k+3])
+		traceState(tr, r, "t_sch", wt[k+0], wt[k+1], wt[k+2], wt[k+3])
 		s0 ^= wk[k+0] ^ wt[k+0]
 		s1 ^= wk[k+1] ^ wt[k+1]
 		s2 ^= wk[k+2] ^ wt[k+2]
@@ -30,12 +52,21 @@ func encryptBlock(wk, wt []uint32, dst, src []byte) {
 		k += 4
 	}
 
+	traceState(tr, nr, "start", s0, s1, s2, s3)
 	s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+	traceState(tr, nr, "s_box", s0, s1, s2, s3)
 	s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
+	traceState(tr, nr, "s_row", s0, s1, s2, s3)
+	if finalMix {
+		s0, s1, s2, s3 = mixColumns(s0, s1, s2, s3)
+		traceState(tr, nr, "m_col", s0, s1, s2, s3)
+	}
+	traceState(tr, nr, "k_sch", wk[k+0], wk[k+1], wk[k+2], wk[k+3])
 	s0 ^= wk[k+0]
 	s1 ^= wk[k+1]
 	s2 ^= wk[k+2]
 	s3 ^= wk[k+3]
+	traceState(tr, nr, "output", s0, s1, s2, s3)
 
 	binary.BigEndian.PutUint32(dst[0:4], s0)
 	binary.BigEndian.PutUint32(dst[4:8], s1)
@@ -43,37 +74,54 @@ func encryptBlock(wk, wt []uint32, dst, src []byte) {
 	binary.BigEndian.PutUint32(dst[12:16], s3)
 }
 
-func decrptyBlock(wk, wt []uint32, dst, src []byte) {
+func decryptRounds(wk, wt []uint32, finalMix bool, tr trace.Tracer, dst, src []byte) {
 	s0 := binary.BigEndian.Uint32(src[0:4])
 	s1 := binary.BigEndian.Uint32(src[4:8])
 	s2 := binary.BigEndian.Uint32(src[8:12])
 	s3 := binary.BigEndian.Uint32(src[12:16])
 
-	nr := 10
+	nr := len(wk)/4 - 1
 	k := 4 * nr
+	traceState(tr, 0, "iinput", s0, s1, s2, s3)
+	traceState(tr, 0, "ik_sch", wk[k+0], wk[k+1], wk[k+2], wk[k+3])
 	s0 ^= wk[k+0]
 	s1 ^= wk[k+1]
 	s2 ^= wk[k+2]
 	s3 ^= wk[k+3]
+	if finalMix {
+		s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
+	}
 
 	for r := 1; r < nr; r++ {
+		traceState(tr, r, "istart", s0, s1, s2, s3)
 		s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+		traceState(tr, r, "is_row", s0, s1, s2, s3)
 		s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+		traceState(tr, r, "is_box", s0, s1, s2, s3)
 		k -= 4
+		traceState(tr, r, "ik_sch", wk[k+0], wk[k+1], wk[k+2], wk[k+3])
+		traceState(tr, r, "it_sch", wt[k+0], wt[k+1], wt[k+2], wt[k+3])
 		s0 ^= wk[k+0] ^ wt[k+0]
 		s1 ^= wk[k+1] ^ wt[k+1]
 		s2 ^= wk[k+2] ^ wt[k+2]
 		s3 ^= wk[k+3] ^ wt[k+3]
+		traceState(tr, r, "ik_add", s0, s1, s2, s3)
 		s0, s1, s2, s3 = invMixColumns(s0, s1, s2, s3)
 	}
 
+	traceState(tr, nr, "istart", s0, s1, s2, s3)
 	s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+	traceState(tr, nr, "is_row", s0, s1, s2, s3)
 	s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+	traceState(tr, nr, "is_box", s0, s1, s2, s3)
 	k -= 4
+	traceState(tr, nr, "ik_sch", wk[k+0], wk[k+1], wk[k+2], wk[k+3])
+	traceState(tr, nr, "it_sch", wt[k+0], wt[k+1], wt[k+2], wt[k+3])
 	s0 ^= wk[k+0] ^ wt[k+0]
 	s1 ^= wk[k+1] ^ wt[k+1]
 	s2 ^= wk[k+2] ^ wt[k+2]
 	s3 ^= wk[k+3] ^ wt[k+3]
+	traceState(tr, nr, "ioutput", s0, s1, s2, s3)
 
 	binary.BigEndian.PutUint32(dst[0:4], s0)
 	binary.BigEndian.PutUint32(dst[4:8], s1)
@@ -81,8 +129,24 @@ func decrptyBlock(wk, wt []uint32, dst, src []byte) {
 	binary.BigEndian.PutUint32(dst[12:16], s3)
 }
 
+// traceState reports the four columns to tr if it is set.
+func traceState(tr trace.Tracer, round int, step string, s0, s1, s2, s3 uint32) {
+	if tr != nil {
+		traceColumns(tr, round, step, s0, s1, s2, s3)
+	}
+}
+
+func traceColumns(tr trace.Tracer, round int, step string, s0, s1, s2, s3 uint32) {
+	var b [16]byte
+	binary.BigEndian.PutUint32(b[0:4], s0)
+	binary.BigEndian.PutUint32(b[4:8], s1)
+	binary.BigEndian.PutUint32(b[8:12], s2)
+	binary.BigEndian.PutUint32(b[12:16], s3)
+	tr.Trace(round, step, b[:])
+}
+
 func subw(t uint32) uint32 {
-	return uint32(sbox0[t>>24])<<24 | uint32(sbox0[t>>16&0xff])<<16 | uint32(sbox0[t>>8&0xff])<<8 | uint32(sbox0[t&0xff])
+	return uint32(gf256.AESSBox[t>>24])<<24 | uint32(gf256.AESSBox[t>>16&0xff])<<16 | uint32(gf256.AESSBox[t>>8&0xff])<<8 | uint32(gf256.AESSBox[t&0xff])
 }
 
 func rotw(t uint32) uint32 {
@@ -95,27 +159,35 @@ func keyExpansion(key []byte, wk []uint32) {
 	}
 	i := 0
 	nk := len(key) / 4
-	w := make([]uint32, 13)
+	var buf [17]uint32
+	w := buf[:len(wk)/4+2]
 	for ; i < nk; i++ {
 		w[i] = binary.BigEndian.Uint32(key[4*i:])
 	}
 	for ; i < len(w); i++ {
 		t := w[i-1]
 		if i%nk == 0 {
-			t = subw(rotw(t)) ^ rcon[i/nk]
+			t = subw(rotw(t)) ^ uint32(gf256.AESRcon[i/nk])<<24
 		}
 		w[i] = w[i-nk] ^ t
 	}
+	expandWords(w[:], wk)
+}
 
-	for r := 0; r < 9; r++ {
+// expandWords spreads the first nr+3 words w of an AES-128 key schedule over
+// the maes schedule wk of nr+1 round keys. The full cipher has nr = 10, so
+// that is 13 words over 44.
+func expandWords(w, wk []uint32) {
+	nr := len(wk)/4 - 1
+	for r := 0; r < nr-1; r++ {
 		i := 4 * r
 		wk[i] = w[r]
 		wk[i+1] = w[r]
 		wk[i+2] = w[r]
 		wk[i+3] = w[r]
 	}
-	r := 9
-	i = 4 * r
+	r := nr - 1
+	i := 4 * r
 	wk[i] = w[r]
 	wk[i+1] = w[r+1]
 	wk[i+2] = 0
@@ -144,14 +216,14 @@ func tweakExpansion(tweak []byte, trcon, wt []uint32) {
 
 func subBytes(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 	f := func(t uint32) uint32 {
-		return uint32(sbox0[t>>24])<<24 | uint32(sbox0[t>>16&0xff])<<16 | uint32(sbox0[t>>8&0xff])<<8 | uint32(sbox0[t&0xff])
+		return uint32(gf256.AESSBox[t>>24])<<24 | uint32(gf256.AESSBox[t>>16&0xff])<<16 | uint32(gf256.AESSBox[t>>8&0xff])<<8 | uint32(gf256.AESSBox[t&0xff])
 	}
 	return f(s0), f(s1), f(s2), f(s3)
 }
 
 func invSubBytes(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 	f := func(t uint32) uint32 {
-		return uint32(sbox1[t>>24])<<24 | uint32(sbox1[t>>16&0xff])<<16 | uint32(sbox1[t>>8&0xff])<<8 | uint32(sbox1[t&0xff])
+		return uint32(gf256.AESInvSBox[t>>24])<<24 | uint32(gf256.AESInvSBox[t>>16&0xff])<<16 | uint32(gf256.AESInvSBox[t>>8&0xff])<<8 | uint32(gf256.AESInvSBox[t&0xff])
 	}
 	return f(s0), f(s1), f(s2), f(s3)
 }
@@ -173,25 +245,27 @@ func invShiftRows(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
 }
 
 func mixColumns(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
-	f := func(t uint32) uint32 {
-		var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
-		d0 := mul2[b0] ^ mul3[b1] ^ b2 ^ b3
-		d1 := b0 ^ mul2[b1] ^ mul3[b2] ^ b3
-		d2 := b0 ^ b1 ^ mul2[b2] ^ mul3[b3]
-		d3 := mul3[b0] ^ b1 ^ b2 ^ mul2[b3]
-		return binary.BigEndian.Uint32([]byte{d0, d1, d2, d3})
-	}
-	return f(s0), f(s1), f(s2), f(s3)
+	return mixColumn(s0), mixColumn(s1), mixColumn(s2), mixColumn(s3)
 }
 
 func invMixColumns(s0, s1, s2, s3 uint32) (uint32, uint32, uint32, uint32) {
-	f := func(t uint32) uint32 {
-		var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
-		d0 := mul14[b0] ^ mul11[b1] ^ mul13[b2] ^ mul9[b3]
-		d1 := mul9[b0] ^ mul14[b1] ^ mul11[b2] ^ mul13[b3]
-		d2 := mul13[b0] ^ mul9[b1] ^ mul14[b2] ^ mul11[b3]
-		d3 := mul11[b0] ^ mul13[b1] ^ mul9[b2] ^ mul14[b3]
-		return binary.BigEndian.Uint32([]byte{d0, d1, d2, d3})
-	}
-	return f(s0), f(s1), f(s2), f(s3)
+	return invMixColumn(s0), invMixColumn(s1), invMixColumn(s2), invMixColumn(s3)
+}
+
+func mixColumn(t uint32) uint32 {
+	var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
+	d0 := gf256.AESMul2[b0] ^ gf256.AESMul3[b1] ^ b2 ^ b3
+	d1 := b0 ^ gf256.AESMul2[b1] ^ gf256.AESMul3[b2] ^ b3
+	d2 := b0 ^ b1 ^ gf256.AESMul2[b2] ^ gf256.AESMul3[b3]
+	d3 := gf256.AESMul3[b0] ^ b1 ^ b2 ^ gf256.AESMul2[b3]
+	return uint32(d0)<<24 | uint32(d1)<<16 | uint32(d2)<<8 | uint32(d3)
+}
+
+func invMixColumn(t uint32) uint32 {
+	var b0, b1, b2, b3 byte = byte(t >> 24), byte(t >> 16 & 0xff), byte(t >> 8 & 0xff), byte(t & 0xff)
+	d0 := gf256.AESMul14[b0] ^ gf256.AESMul11[b1] ^ gf256.AESMul13[b2] ^ gf256.AESMul9[b3]
+	d1 := gf256.AESMul9[b0] ^ gf256.AESMul14[b1] ^ gf256.AESMul11[b2] ^ gf256.AESMul13[b3]
+	d2 := gf256.AESMul13[b0] ^ gf256.AESMul9[b1] ^ gf256.AESMul14[b2] ^ gf256.AESMul11[b3]
+	d3 := gf256.AESMul11[b0] ^ gf256.AESMul13[b1] ^ gf256.AESMul9[b2] ^ gf256.AESMul14[b3]
+	return uint32(d0)<<24 | uint32(d1)<<16 | uint32(d2)<<8 | uint32(d3)
 }
@@ -1,13 +1,11 @@
 package maes
 
 import (
-	"encoding/binary"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
-	"golang.org/x/crypto/sha3"
 )
 
 func TestSubBytes(t *testing.T) {
@@ -152,15 +150,8 @@ func TestMCipher(t *testing.T) {
 		0x0e, 0x0f,
 	}
 
-	trcon := make([]uint32, 10)
 	tweak := []byte("this is a tweak")
-	rt := make([]byte, 4*len(trcon))
-	sh := sha3.NewShake256()
-	sh.Write(tweak)
-	sh.Read(rt)
-	for i := range trcon {
-		trcon[i] = binary.BigEndian.Uint32(rt[4*i:])
-	}
+	trcon := expandTrcon(tweak, 10)
 	wk := make([]uint32, 44)
 	wt := make([]uint32, 40)
 	keyExpansion(key, wk)
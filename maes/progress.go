@@ -0,0 +1,146 @@
+package maes
+
+import (
+	"fmt"
+	"time"
+)
+
+// Progress is a snapshot of a running key search.
+type Progress struct {
+	// Tried holds the number of candidates tested by each worker since the
+	// search started or resumed.
+	Tried []uint64
+	// Total is the sum of Tried.
+	Total uint64
+	// Done is the number of candidates covered so far, including any
+	// restored from a checkpoint.
+	Done uint64
+	// Space is the number of candidates the search covers.
+	Space uint64
+	// Elapsed is the time since the search started or resumed.
+	Elapsed time.Duration
+	// Rate is the overall throughput in candidates per second.
+	Rate float64
+	// Remaining estimates the time left to exhaust the space at Rate.
+	Remaining time.Duration
+}
+
+// Option configures GuessKey.
+type Option func(*guessConfig)
+
+type guessConfig struct {
+	start              uint64
+	end                uint64
+	workers            int
+	progress           func(Progress)
+	progressInterval   time.Duration
+	checkpointPath     string
+	checkpointInterval time.Duration
+	confirm            [][2][]byte
+	engine             Engine
+}
+
+// Engine selects how GuessKey evaluates candidates.
+type Engine int
+
+const (
+	// TableEngine tests one candidate at a time with table lookups.
+	TableEngine Engine = iota
+	// BitslicedEngine filters 64 candidates per pass with a bitsliced
+	// round function and rechecks the survivors with TableEngine.
+	BitslicedEngine
+)
+
+// WithEngine selects the candidate evaluation engine. The default is
+// TableEngine.
+func WithEngine(e Engine) Option {
+	return func(c *guessConfig) {
+		c.engine = e
+	}
+}
+
+// WithRange restricts GuessKey to the candidates in [start, end), where end
+// is at most 2^32.
+func WithRange(start, end uint64) Option {
+	return func(c *guessConfig) {
+		c.start = start
+		c.end = end
+	}
+}
+
+// WithWorkers sets the number of goroutines GuessKey splits its range
+// between. The default is 16.
+func WithWorkers(n int) Option {
+	return func(c *guessConfig) {
+		c.workers = n
+	}
+}
+
+// WithProgress makes GuessKey call fn every interval, and once more when the
+// search stops, with the current Progress. Calls come from a single goroutine.
+// interval must be positive.
+func WithProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(c *guessConfig) {
+		c.progress = fn
+		c.progressInterval = interval
+	}
+}
+
+// WithConfirm makes GuessKey accept a key only if it also encrypts plaintext
+// to ciphertext under the same tweak, ruling out false positives. It may be
+// given more than once.
+func WithConfirm(plaintext, ciphertext []byte) Option {
+	return func(c *guessConfig) {
+		c.confirm = append(c.confirm, [2][]byte{plaintext, ciphertext})
+	}
+}
+
+func newGuessConfig(opts []Option) (*guessConfig, error) {
+	c := &guessConfig{
+		end:     1 << 32,
+		workers: guessWorkers,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.start >= c.end || c.end > 1<<32 {
+		return nil, fmt.Errorf("maes: invalid search range [%#x, %#x)", c.start, c.end)
+	}
+	if c.workers <= 0 {
+		return nil, fmt.Errorf("maes: invalid worker count %d", c.workers)
+	}
+	if c.progress != nil && c.progressInterval <= 0 {
+		return nil, fmt.Errorf("maes: invalid progress interval %v", c.progressInterval)
+	}
+	if c.checkpointPath != "" && c.checkpointInterval <= 0 {
+		return nil, fmt.Errorf("maes: invalid checkpoint interval %v", c.checkpointInterval)
+	}
+	return c, nil
+}
+
+// progressFlush is how many candidates a worker tests between updates of its
+// shared position.
+const progressFlush = 1 << 16
+
+// progress reads the worker positions into a Progress.
+func (s *search) progress() Progress {
+	next := s.positions()
+	p := Progress{
+		Tried:   make([]uint64, len(next)),
+		Done:    s.space,
+		Space:   s.space,
+		Elapsed: time.Since(s.start),
+	}
+	for i := range next {
+		p.Tried[i] = next[i] - s.begin[i]
+		p.Total += p.Tried[i]
+		p.Done -= s.end[i] - next[i]
+	}
+	if p.Elapsed > 0 {
+		p.Rate = float64(p.Total) / p.Elapsed.Seconds()
+	}
+	if p.Rate > 0 {
+		p.Remaining = time.Duration(float64(p.Space-p.Done) / p.Rate * float64(time.Second))
+	}
+	return p
+}
@@ -0,0 +1,72 @@
+package maes
+
+import (
+	"encoding/binary"
+
+	"github.com/RainbowDashy/cipher/gf256"
+)
+
+// encryptBlockTTable runs len(wk)/4-1 rounds, the last without MixColumns.
+func encryptBlockTTable(wk, wt []uint32, dst, src []byte) {
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ wk[0] ^ wt[0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ wk[1] ^ wt[1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ wk[2] ^ wt[2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ wk[3] ^ wt[3]
+
+	nr := len(wk)/4 - 1
+	k := 4
+	for r := 1; r < nr; r++ {
+		t0 := gf256.AESTe0[s0>>24] ^ gf256.AESTe1[s1>>16&0xff] ^ gf256.AESTe2[s2>>8&0xff] ^ gf256.AESTe3[s3&0xff] ^ wk[k+0] ^ wt[k+0]
+		t1 := gf256.AESTe0[s1>>24] ^ gf256.AESTe1[s2>>16&0xff] ^ gf256.AESTe2[s3>>8&0xff] ^ gf256.AESTe3[s0&0xff] ^ wk[k+1] ^ wt[k+1]
+		t2 := gf256.AESTe0[s2>>24] ^ gf256.AESTe1[s3>>16&0xff] ^ gf256.AESTe2[s0>>8&0xff] ^ gf256.AESTe3[s1&0xff] ^ wk[k+2] ^ wt[k+2]
+		t3 := gf256.AESTe0[s3>>24] ^ gf256.AESTe1[s0>>16&0xff] ^ gf256.AESTe2[s1>>8&0xff] ^ gf256.AESTe3[s2&0xff] ^ wk[k+3] ^ wt[k+3]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	s0, s1, s2, s3 = shiftRows(s0, s1, s2, s3)
+	s0, s1, s2, s3 = subBytes(s0, s1, s2, s3)
+	s0 ^= wk[k+0]
+	s1 ^= wk[k+1]
+	s2 ^= wk[k+2]
+	s3 ^= wk[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:4], s0)
+	binary.BigEndian.PutUint32(dst[4:8], s1)
+	binary.BigEndian.PutUint32(dst[8:12], s2)
+	binary.BigEndian.PutUint32(dst[12:16], s3)
+}
+
+// decryptBlockTTable runs the equivalent inverse cipher. The tweak changes
+// every call, so InvMixColumns is applied to the middle round keys on the
+// fly. Like encryptBlockTTable it runs len(wk)/4-1 rounds.
+func decryptBlockTTable(wk, wt []uint32, dst, src []byte) {
+	nr := len(wk)/4 - 1
+	k := 4 * nr
+	s0 := binary.BigEndian.Uint32(src[0:4]) ^ wk[k+0]
+	s1 := binary.BigEndian.Uint32(src[4:8]) ^ wk[k+1]
+	s2 := binary.BigEndian.Uint32(src[8:12]) ^ wk[k+2]
+	s3 := binary.BigEndian.Uint32(src[12:16]) ^ wk[k+3]
+
+	for r := 1; r < nr; r++ {
+		k -= 4
+		t0 := gf256.AESTd0[s0>>24] ^ gf256.AESTd1[s3>>16&0xff] ^ gf256.AESTd2[s2>>8&0xff] ^ gf256.AESTd3[s1&0xff] ^ invMixColumn(wk[k+0]^wt[k+0])
+		t1 := gf256.AESTd0[s1>>24] ^ gf256.AESTd1[s0>>16&0xff] ^ gf256.AESTd2[s3>>8&0xff] ^ gf256.AESTd3[s2&0xff] ^ invMixColumn(wk[k+1]^wt[k+1])
+		t2 := gf256.AESTd0[s2>>24] ^ gf256.AESTd1[s1>>16&0xff] ^ gf256.AESTd2[s0>>8&0xff] ^ gf256.AESTd3[s3&0xff] ^ invMixColumn(wk[k+2]^wt[k+2])
+		t3 := gf256.AESTd0[s3>>24] ^ gf256.AESTd1[s2>>16&0xff] ^ gf256.AESTd2[s1>>8&0xff] ^ gf256.AESTd3[s0&0xff] ^ invMixColumn(wk[k+3]^wt[k+3])
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	s0, s1, s2, s3 = invShiftRows(s0, s1, s2, s3)
+	s0, s1, s2, s3 = invSubBytes(s0, s1, s2, s3)
+	k -= 4
+	s0 ^= wk[k+0] ^ wt[k+0]
+	s1 ^= wk[k+1] ^ wt[k+1]
+	s2 ^= wk[k+2] ^ wt[k+2]
+	s3 ^= wk[k+3] ^ wt[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:4], s0)
+	binary.BigEndian.PutUint32(dst[4:8], s1)
+	binary.BigEndian.PutUint32(dst[8:12], s2)
+	binary.BigEndian.PutUint32(dst[12:16], s3)
+}
@@ -0,0 +1,107 @@
+package maes
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTTable(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	key := make([]byte, 16)
+	tweak := make([]byte, 16)
+	src := make([]byte, BlockSize)
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	trcon := make([]uint32, 10)
+	wk := make([]uint32, 44)
+	wt := make([]uint32, 40)
+	for i := 0; i < 100; i++ {
+		rg.Read(key)
+		rg.Read(tweak)
+		rg.Read(src)
+		for j := range trcon {
+			trcon[j] = rg.Uint32()
+		}
+		keyExpansion(key, wk)
+		tweakExpansion(tweak, trcon, wt)
+
+		encryptBlock(wk, wt, want, src)
+		encryptBlockTTable(wk, wt, dst, src)
+		a.Equal(want, dst)
+
+		decrptyBlock(wk, wt, want, src)
+		decryptBlockTTable(wk, wt, dst, src)
+		a.Equal(want, dst)
+	}
+}
+
+func TestTTableBackend(t *testing.T) {
+	a := require.New(t)
+	key := []byte("0123456789abcdef")
+	tweak := []byte("this is a tweak")
+	ref, err := NewWithBackend(key, tweak, Reference)
+	a.NoError(err)
+	tt, err := NewWithBackend(key, tweak, TTable)
+	a.NoError(err)
+
+	src := []byte("sixteen byte msg")
+	want := make([]byte, BlockSize)
+	dst := make([]byte, BlockSize)
+	ref.Encrypt(want, src, tweak)
+	tt.Encrypt(dst, src, tweak)
+	a.Equal(want, dst)
+	tt.Decrypt(dst, dst, tweak)
+	a.Equal(src, dst)
+
+	_, err = NewWithBackend(key, tweak, Backend(-1))
+	a.Error(err)
+}
+
+func benchmarkBackend(b *testing.B, backend Backend, decrypt bool) {
+	c, err := NewWithBackend(make([]byte, 16), nil, backend)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, BlockSize)
+	tweak := []byte("this is a tweak")
+	b.SetBytes(BlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if decrypt {
+			c.Decrypt(buf, buf, tweak)
+		} else {
+			c.Encrypt(buf, buf, tweak)
+		}
+	}
+}
+
+func BenchmarkEncryptReference(b *testing.B) { benchmarkBackend(b, Reference, false) }
+func BenchmarkEncryptTTable(b *testing.B)    { benchmarkBackend(b, TTable, false) }
+func BenchmarkDecryptReference(b *testing.B) { benchmarkBackend(b, Reference, true) }
+func BenchmarkDecryptTTable(b *testing.B)    { benchmarkBackend(b, TTable, true) }
+
+// The Cipher benchmarks include the per-call tweak expansion, so these
+// compare the block functions alone.
+func BenchmarkEncryptBlockReference(b *testing.B) {
+	wk := make([]uint32, 44)
+	wt := make([]uint32, 40)
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	for i := 0; i < b.N; i++ {
+		encryptBlock(wk, wt, buf, buf)
+	}
+}
+
+func BenchmarkEncryptBlockTTable(b *testing.B) {
+	wk := make([]uint32, 44)
+	wt := make([]uint32, 40)
+	buf := make([]byte, BlockSize)
+	b.SetBytes(BlockSize)
+	for i := 0; i < b.N; i++ {
+		encryptBlockTTable(wk, wt, buf, buf)
+	}
+}
@@ -1 +1,269 @@
 package skinny
+
+import (
+	"strconv"
+)
+
+// KeySizeError is returned when the key and tweak lengths do not add up to a
+// valid SKINNY tweakey.
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "skinny: invalid key size " + strconv.Itoa(int(k))
+}
+
+// TweakSizeError is returned when the tweak length is not a whole number of
+// tweakey lanes.
+type TweakSizeError int
+
+func (k TweakSizeError) Error() string {
+	return "skinny: invalid tweak size " + strconv.Itoa(int(k))
+}
+
+// TweakableBlock is a block cipher that takes a tweak alongside every block.
+type TweakableBlock interface {
+	// BlockSize returns the cipher's block size.
+	BlockSize() int
+
+	// Encrypt encrypts the first block in src into dst under tweak.
+	// Dst and src must overlap entirely or not at all.
+	Encrypt(dst, src, tweak []byte)
+
+	// Decrypt decrypts the first block in src into dst under tweak.
+	// Dst and src must overlap entirely or not at all.
+	Decrypt(dst, src, tweak []byte)
+}
+
+// Cipher is a SKINNY instance. The tweakey is the tweak followed by the key,
+// split into TK1, TK2 and TK3 lanes of one block each, so the tweak always
+// occupies the leading lanes.
+type Cipher struct {
+	cellBits  int
+	nr        int
+	tweakSize int
+	sbox      []byte
+	invSbox   []byte
+	// rtk holds the key lanes' contribution to each round tweakey.
+	rtk [][8]byte
+}
+
+var _ TweakableBlock = (*Cipher)(nil)
+
+// rounds is indexed by cell size (0 for 4-bit, 1 for 8-bit) and the number of
+// tweakey lanes.
+var rounds = [2][4]int{
+	{0, 32, 36, 40},
+	{0, 40, 48, 56},
+}
+
+const maxRounds = 56
+
+// New64 creates a SKINNY-64 instance with a 64-bit block. The tweakey is
+// len(key)+tweakSize bytes and selects SKINNY-64/64, -64/128 or -64/192.
+func New64(key []byte, tweakSize int) (*Cipher, error) {
+	return newCipher(4, key, tweakSize)
+}
+
+// New128 creates a SKINNY-128 instance with a 128-bit block. The tweakey is
+// len(key)+tweakSize bytes and selects SKINNY-128/128, -128/256 or -128/384.
+func New128(key []byte, tweakSize int) (*Cipher, error) {
+	return newCipher(8, key, tweakSize)
+}
+
+func newCipher(cellBits int, key []byte, tweakSize int) (*Cipher, error) {
+	bs := 2 * cellBits
+	if tweakSize < 0 || tweakSize%bs != 0 {
+		return nil, TweakSizeError(tweakSize)
+	}
+	if len(key) == 0 || len(key)%bs != 0 || len(key)+tweakSize > 3*bs {
+		return nil, KeySizeError(len(key))
+	}
+	c := &Cipher{
+		cellBits:  cellBits,
+		nr:        rounds[cellBits/8][(len(key)+tweakSize)/bs],
+		tweakSize: tweakSize,
+	}
+	if cellBits == 4 {
+		c.sbox, c.invSbox = sbox4[:], invSbox4[:]
+	} else {
+		c.sbox, c.invSbox = sbox8[:], invSbox8[:]
+	}
+	c.rtk = make([][8]byte, c.nr)
+	for i := 0; i < len(key); i += bs {
+		c.expandLane(c.rtk, key[i:], (tweakSize+i)/bs)
+	}
+	return c, nil
+}
+
+func (c *Cipher) BlockSize() int {
+	return 2 * c.cellBits
+}
+
+func (c *Cipher) Encrypt(dst, src, tweak []byte) {
+	c.check(dst, src, tweak)
+	var rtk [maxRounds][8]byte
+	c.roundTweakeys(rtk[:c.nr], tweak)
+
+	var s [16]byte
+	c.load(&s, src)
+	for r := 0; r < c.nr; r++ {
+		for i := range s {
+			s[i] = c.sbox[s[i]]
+		}
+		s[0] ^= rc[r] & 0xf
+		s[4] ^= rc[r] >> 4
+		s[8] ^= 0x2
+		for i := 0; i < 8; i++ {
+			s[i] ^= rtk[r][i]
+		}
+		shiftRows(&s)
+		mixColumns(&s)
+	}
+	c.store(dst, &s)
+}
+
+func (c *Cipher) Decrypt(dst, src, tweak []byte) {
+	c.check(dst, src, tweak)
+	var rtk [maxRounds][8]byte
+	c.roundTweakeys(rtk[:c.nr], tweak)
+
+	var s [16]byte
+	c.load(&s, src)
+	for r := c.nr - 1; r >= 0; r-- {
+		invMixColumns(&s)
+		invShiftRows(&s)
+		for i := 0; i < 8; i++ {
+			s[i] ^= rtk[r][i]
+		}
+		s[0] ^= rc[r] & 0xf
+		s[4] ^= rc[r] >> 4
+		s[8] ^= 0x2
+		for i := range s {
+			s[i] = c.invSbox[s[i]]
+		}
+	}
+	c.store(dst, &s)
+}
+
+func (c *Cipher) check(dst, src, tweak []byte) {
+	bs := c.BlockSize()
+	if len(src) < bs {
+		panic("skinny: input not full block")
+	}
+	if len(dst) < bs {
+		panic("skinny: output not full block")
+	}
+	if len(tweak) != c.tweakSize {
+		panic("skinny: invalid tweak size")
+	}
+}
+
+// roundTweakeys combines the cached key lanes with the lanes expanded from
+// tweak.
+func (c *Cipher) roundTweakeys(rtk [][8]byte, tweak []byte) {
+	copy(rtk, c.rtk)
+	bs := c.BlockSize()
+	for i := 0; i < len(tweak); i += bs {
+		c.expandLane(rtk, tweak[i:], i/bs)
+	}
+}
+
+// expandLane XORs the first two rows of tweakey lane tk into every round
+// tweakey, applying PT and the lane's LFSR between rounds. Lane 0 is TK1.
+func (c *Cipher) expandLane(rtk [][8]byte, tk []byte, lane int) {
+	var t [16]byte
+	c.load(&t, tk)
+	for r := range rtk {
+		for i := 0; i < 8; i++ {
+			rtk[r][i] ^= t[i]
+		}
+		var u [16]byte
+		for i := range u {
+			u[i] = t[pt[i]]
+		}
+		t = u
+		for i := 0; i < 8; i++ {
+			t[i] = c.lfsr(lane, t[i])
+		}
+	}
+}
+
+// lfsr updates a cell in the first two rows of TK2 or TK3.
+func (c *Cipher) lfsr(lane int, x byte) byte {
+	switch {
+	case lane == 1 && c.cellBits == 4:
+		return x<<1&0xe | (x>>3^x>>2)&1
+	case lane == 2 && c.cellBits == 4:
+		return x>>1 | (x<<3^x)&8
+	case lane == 1:
+		return x<<1 | (x>>7^x>>5)&1
+	case lane == 2:
+		return x>>1 | (x<<7^x<<1)&0x80
+	}
+	return x
+}
+
+// load unpacks a block into one cell per byte, high nibble first for 4-bit
+// cells.
+func (c *Cipher) load(s *[16]byte, src []byte) {
+	if c.cellBits == 8 {
+		copy(s[:], src[:16])
+		return
+	}
+	for i := 0; i < 8; i++ {
+		s[2*i] = src[i] >> 4
+		s[2*i+1] = src[i] & 0xf
+	}
+}
+
+func (c *Cipher) store(dst []byte, s *[16]byte) {
+	if c.cellBits == 8 {
+		copy(dst[:16], s[:])
+		return
+	}
+	for i := 0; i < 8; i++ {
+		dst[i] = s[2*i]<<4 | s[2*i+1]
+	}
+}
+
+// pt is the tweakey cell permutation PT.
+var pt = [16]int{9, 15, 8, 13, 10, 14, 12, 11, 0, 1, 2, 3, 4, 5, 6, 7}
+
+// shiftRows rotates row i right by i cells.
+func shiftRows(s *[16]byte) {
+	s[4], s[5], s[6], s[7] = s[7], s[4], s[5], s[6]
+	s[8], s[9], s[10], s[11] = s[10], s[11], s[8], s[9]
+	s[12], s[13], s[14], s[15] = s[13], s[14], s[15], s[12]
+}
+
+func invShiftRows(s *[16]byte) {
+	s[4], s[5], s[6], s[7] = s[5], s[6], s[7], s[4]
+	s[8], s[9], s[10], s[11] = s[10], s[11], s[8], s[9]
+	s[12], s[13], s[14], s[15] = s[15], s[12], s[13], s[14]
+}
+
+// mixColumns multiplies each column by the binary matrix
+//
+//	1 0 1 1
+//	1 0 0 0
+//	0 1 1 0
+//	1 0 1 0
+func mixColumns(s *[16]byte) {
+	for j := 0; j < 4; j++ {
+		r0, r1, r2, r3 := s[j], s[4+j], s[8+j], s[12+j]
+		s[j] = r0 ^ r2 ^ r3
+		s[4+j] = r0
+		s[8+j] = r1 ^ r2
+		s[12+j] = r0 ^ r2
+	}
+}
+
+func invMixColumns(s *[16]byte) {
+	for j := 0; j < 4; j++ {
+		a, b, c, d := s[j], s[4+j], s[8+j], s[12+j]
+		s[j] = b
+		s[4+j] = b ^ c ^ d
+		s[8+j] = b ^ d
+		s[12+j] = a ^ d
+	}
+}
@@ -0,0 +1,125 @@
+package skinny
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/maes"
+	"github.com/stretchr/testify/require"
+)
+
+var _ maes.TweakableBlock = (*Cipher)(nil)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Test vectors from Appendix B of the SKINNY paper.
+var vectors = []struct {
+	name       string
+	blockSize  int
+	key        string
+	plaintext  string
+	ciphertext string
+}{
+	{"64/64", 8, "f5269826fc681238", "06034f957724d19d", "bb39dfb2429b8ac7"},
+	{"64/128", 8, "9eb93640d088da6376a39d1c8bea71e1", "cf16cfe8fd0f98aa", "6ceda1f43de92b9e"},
+	{"64/192", 8, "ed00c85b120d68618753e24bfd908f60b2dbb41b422dfcd0", "530c61d35e8663c3", "dd2cf1a8f330303c"},
+	{"128/128", 16, "4f55cfb0520cac52fd92c15f37073e93", "f20adb0eb08b648a3b2eeed1f0adda14", "22ff30d498ea62d7e45b476e33675b74"},
+	{"128/256", 16, "009cec81605d4ac1d2ae9e3085d7a1f31ac123ebfc00fddcf01046ceeddfcab3", "3a0c47767a26a68dd382a695e7022e25", "b731d98a4bde147a7ed4a6f16b9b587f"},
+	{"128/384", 16, "df889548cfc7ea52d296339301797449ab588a34a47f1ab2dfe9c8293fbea9a5ab1afac2611012cd8cef952618c3ebe8", "a3994b66ad85a3459f44e92b08f550cb", "94ecf589e2017c601b38c6346a10dcfa"},
+}
+
+func newForSize(blockSize int, key []byte, tweakSize int) (*Cipher, error) {
+	if blockSize == 8 {
+		return New64(key, tweakSize)
+	}
+	return New128(key, tweakSize)
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			a := require.New(t)
+			key, plaintext, ciphertext := unhex(v.key), unhex(v.plaintext), unhex(v.ciphertext)
+			c, err := newForSize(v.blockSize, key, 0)
+			a.NoError(err)
+			a.Equal(v.blockSize, c.BlockSize())
+
+			dst := make([]byte, v.blockSize)
+			c.Encrypt(dst, plaintext, nil)
+			a.Equal(ciphertext, dst)
+			c.Decrypt(dst, dst, nil)
+			a.Equal(plaintext, dst)
+		})
+	}
+}
+
+func TestTweak(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			a := require.New(t)
+			key, plaintext, ciphertext := unhex(v.key), unhex(v.plaintext), unhex(v.ciphertext)
+			for n := v.blockSize; n < len(key); n += v.blockSize {
+				c, err := newForSize(v.blockSize, key[n:], n)
+				a.NoError(err)
+				dst := make([]byte, v.blockSize)
+				c.Encrypt(dst, plaintext, key[:n])
+				a.Equal(ciphertext, dst)
+				c.Decrypt(dst, dst, key[:n])
+				a.Equal(plaintext, dst)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	a := require.New(t)
+	rg := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, bs := range []int{8, 16} {
+		key := make([]byte, bs)
+		tweak := make([]byte, 2*bs)
+		src := make([]byte, bs)
+		dst := make([]byte, bs)
+		rg.Read(key)
+		c, err := newForSize(bs, key, len(tweak))
+		a.NoError(err)
+		for i := 0; i < 100; i++ {
+			rg.Read(tweak)
+			rg.Read(src)
+			c.Encrypt(dst, src, tweak)
+			c.Decrypt(dst, dst, tweak)
+			a.Equal(src, dst)
+		}
+	}
+}
+
+func TestSizeErrors(t *testing.T) {
+	a := require.New(t)
+	_, err := New64(make([]byte, 8), 4)
+	a.Equal(TweakSizeError(4), err)
+	_, err = New128(make([]byte, 16), -16)
+	a.Equal(TweakSizeError(-16), err)
+	_, err = New64(make([]byte, 12), 0)
+	a.Equal(KeySizeError(12), err)
+	_, err = New128(make([]byte, 32), 32)
+	a.Equal(KeySizeError(32), err)
+	_, err = New128(nil, 16)
+	a.Equal(KeySizeError(0), err)
+}
+
+func TestSBox(t *testing.T) {
+	a := require.New(t)
+	for i := range sbox4 {
+		a.Equal(byte(i), invSbox4[sbox4[i]])
+	}
+	for i := range sbox8 {
+		a.Equal(byte(i), invSbox8[sbox8[i]])
+	}
+}
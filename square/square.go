@@ -0,0 +1,195 @@
+package square
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/RainbowDashy/cipher/aes"
+	"github.com/RainbowDashy/cipher/gf256"
+)
+
+// maxCandidates bounds the number of last-round keys tried against a known
+// pair before another set is queried.
+const maxCandidates = 1 << 16
+
+// Parity records, for every byte position, which ciphertext values occurred
+// an odd number of times over a set of texts. That is all the balanced-sum
+// test needs.
+type Parity [16][256]bool
+
+// Add records one ciphertext.
+func (p *Parity) Add(c []byte) {
+	for j := range p {
+		p[j][c[j]] = !p[j][c[j]]
+	}
+}
+
+// Candidates returns, for every byte position j, the last-round key bytes k
+// for which the inverse S-box of c[j]^k sums to zero over the recorded
+// ciphertexts.
+func (p *Parity) Candidates() [16][]byte {
+	var cands [16][]byte
+	for j := range p {
+		for k := 0; k < 256; k++ {
+			var sum byte
+			for v, odd := range p[j] {
+				if odd {
+					sum ^= gf256.AESInvSBox[v^k]
+				}
+			}
+			if sum == 0 {
+				cands[j] = append(cands[j], byte(k))
+			}
+		}
+	}
+	return cands
+}
+
+// LambdaSet returns the 256 plaintexts that take every value at byte pos and
+// agree with base everywhere else.
+func LambdaSet(base [16]byte, pos int) [][16]byte {
+	set := make([][16]byte, 256)
+	for v := range set {
+		set[v] = base
+		set[v][pos] = byte(v)
+	}
+	return set
+}
+
+// InvertKeySchedule returns the AES-128 key whose round key number nr is rk.
+func InvertKeySchedule(rk [16]byte, nr int) [16]byte {
+	w := rk
+	for r := nr; r > 0; r-- {
+		for i := 15; i >= 4; i-- {
+			w[i] ^= w[i-4]
+		}
+		w[0] ^= gf256.AESSBox[w[13]] ^ gf256.AESRcon[r]
+		w[1] ^= gf256.AESSBox[w[14]]
+		w[2] ^= gf256.AESSBox[w[15]]
+		w[3] ^= gf256.AESSBox[w[12]]
+	}
+	return w
+}
+
+// Attack recovers the key of oracle, an AES-128 instance reduced to 4 or 5
+// rounds whose last round omits MixColumns, such as the cipher.Block from
+// aes.NewReducedCipher(key, rounds, false).
+//
+// Three rounds map a Λ-set, 256 plaintexts that differ in one byte only, to
+// a set whose bytes all sum to zero. For 4 rounds the attack peels the last
+// round byte by byte: a guess of a last-round key byte survives if the
+// partial decryptions through the inverse S-box sum to zero. Each set lets
+// about one wrong guess per byte through, so sets are queried until the
+// surviving keys are few enough to test against a known pair.
+//
+// For 5 rounds the first round is absorbed by taking all 2^32 values of the
+// main diagonal. Those plaintexts fill column 0 after the first round and so
+// form 2^24 Λ-sets, whatever the key, whose sum stays zero through the next
+// three rounds; the last round is peeled as for 4 rounds. Each such set costs
+// 2^32 queries and up to two are used, so at most 2^33.
+func Attack(oracle cipher.Block, rounds int) ([]byte, error) {
+	var active []int
+	var sets int
+	switch rounds {
+	case 4:
+		active, sets = []int{0}, 8
+	case 5:
+		active, sets = []int{0, 5, 10, 15}, 2
+	default:
+		return nil, errors.New("square: only 4 and 5 rounds are supported")
+	}
+
+	var known [2][16]byte
+	oracle.Encrypt(known[1][:], known[0][:])
+
+	var cands [16][]byte
+	for n := 1; n <= sets; n++ {
+		var base [16]byte
+		for i := range base {
+			base[i] = byte(n)
+		}
+		var p Parity
+		query(oracle, base, active, &p)
+		c := p.Candidates()
+		total := 1
+		for j := range cands {
+			if n == 1 {
+				cands[j] = c[j]
+			} else {
+				cands[j] = intersect(cands[j], c[j])
+			}
+			if len(cands[j]) == 0 {
+				return nil, errors.New("square: no last-round key byte survives")
+			}
+			total *= len(cands[j])
+			if total > maxCandidates {
+				total = maxCandidates + 1
+			}
+		}
+		if total > maxCandidates {
+			continue
+		}
+		if key := search(&cands, rounds, known); key != nil {
+			return key, nil
+		}
+		return nil, errors.New("square: no candidate matches the known pair")
+	}
+	return nil, errors.New("square: too many candidates left")
+}
+
+// query encrypts every plaintext that agrees with base outside active and
+// records the ciphertexts in p.
+func query(oracle cipher.Block, base [16]byte, active []int, p *Parity) {
+	pt := base
+	var ct [16]byte
+	for i := uint64(0); i < 1<<(8*len(active)); i++ {
+		for j, pos := range active {
+			pt[pos] = byte(i >> (8 * j))
+		}
+		oracle.Encrypt(ct[:], pt[:])
+		p.Add(ct[:])
+	}
+}
+
+// search tries every combination of candidate bytes as the last round key.
+func search(cands *[16][]byte, rounds int, known [2][16]byte) []byte {
+	var idx [16]int
+	var rk [16]byte
+	ct := make([]byte, 16)
+	for {
+		for j := range rk {
+			rk[j] = cands[j][idx[j]]
+		}
+		key := InvertKeySchedule(rk, rounds)
+		c, err := aes.NewReducedCipherWithBackend(key[:], rounds, false, aes.TTable)
+		if err != nil {
+			return nil
+		}
+		c.Encrypt(ct, known[0][:])
+		if bytes.Equal(ct, known[1][:]) {
+			return key[:]
+		}
+		j := 0
+		for ; j < 16; j++ {
+			idx[j]++
+			if idx[j] < len(cands[j]) {
+				break
+			}
+			idx[j] = 0
+		}
+		if j == 16 {
+			return nil
+		}
+	}
+}
+
+func intersect(a, b []byte) []byte {
+	var out []byte
+	for _, x := range a {
+		if bytes.IndexByte(b, x) >= 0 {
+			out = append(out, x)
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package square
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/RainbowDashy/cipher/aes"
+	"github.com/RainbowDashy/cipher/gf256"
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestInvertKeySchedule(t *testing.T) {
+	a := require.New(t)
+	// FIPS-197 Appendix A.1.
+	key := unhex("2b7e151628aed2a6abf7158809cf4f3c")
+	tests := []struct {
+		round int
+		rk    string
+	}{
+		{0, "2b7e151628aed2a6abf7158809cf4f3c"},
+		{1, "a0fafe1788542cb123a339392a6c7605"},
+		{4, "ef44a541a8525b7fb671253bdb0bad00"},
+		{5, "d4d1c6f87c839d87caf2b8bc11f915bc"},
+		{10, "d014f9a8c9ee2589e13f0cc8b6630ca6"},
+	}
+	for _, tt := range tests {
+		var rk [16]byte
+		copy(rk[:], unhex(tt.rk))
+		got := InvertKeySchedule(rk, tt.round)
+		a.Equal(key, got[:])
+	}
+}
+
+func TestLambdaSet(t *testing.T) {
+	a := require.New(t)
+	var base [16]byte
+	for i := range base {
+		base[i] = byte(i)
+	}
+	set := LambdaSet(base, 3)
+	a.Len(set, 256)
+	seen := make(map[byte]bool)
+	for _, p := range set {
+		seen[p[3]] = true
+		p[3] = base[3]
+		a.Equal(base, p)
+	}
+	a.Len(seen, 256)
+}
+
+// newRand returns a generator seeded from the clock and logs the seed so a
+// failing run can be repeated.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+func TestBalanced(t *testing.T) {
+	a := require.New(t)
+	rg := newRand(t)
+	key := make([]byte, 16)
+	rg.Read(key)
+	var base [16]byte
+	rg.Read(base[:])
+
+	// Three rounds whose last one keeps MixColumns leave every byte balanced.
+	c, err := aes.NewReducedCipher(key, 3, true)
+	a.NoError(err)
+	var sum, ct [16]byte
+	for _, p := range LambdaSet(base, rg.Intn(16)) {
+		c.Encrypt(ct[:], p[:])
+		for j := range sum {
+			sum[j] ^= ct[j]
+		}
+	}
+	a.Equal([16]byte{}, sum)
+
+	// The true last-round key byte of four rounds always passes. It is the
+	// ciphertext XOR SubBytes and ShiftRows of the state after three rounds.
+	c4, err := aes.NewReducedCipher(key, 4, false)
+	a.NoError(err)
+	var p Parity
+	for _, pt := range LambdaSet(base, 0) {
+		c4.Encrypt(ct[:], pt[:])
+		p.Add(ct[:])
+	}
+	var s3, rk [16]byte
+	c.Encrypt(s3[:], base[:])
+	c4.Encrypt(ct[:], base[:])
+	for j := range rk {
+		row, col := j%4, j/4
+		rk[j] = ct[j] ^ gf256.AESSBox[s3[row+4*((col+row)%4)]]
+	}
+	cands := p.Candidates()
+	for j := range cands {
+		a.Contains(cands[j], rk[j])
+	}
+}
+
+func TestAttack4(t *testing.T) {
+	a := require.New(t)
+	rg := newRand(t)
+	for i := 0; i < 4; i++ {
+		key := make([]byte, 16)
+		rg.Read(key)
+		oracle, err := aes.NewReducedCipher(key, 4, false)
+		a.NoError(err)
+		start := time.Now()
+		got, err := Attack(oracle, 4)
+		a.NoError(err)
+		a.Equal(key, got)
+		t.Logf("attack took %v", time.Since(start))
+	}
+}
+
+// BenchmarkAttack4 should stay well under a second per op.
+func BenchmarkAttack4(b *testing.B) {
+	key := unhex("2b7e151628aed2a6abf7158809cf4f3c")
+	oracle, err := aes.NewReducedCipher(key, 4, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := Attack(oracle, 4); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAttack5(t *testing.T) {
+	if testing.Short() {
+		t.Skip("needs 2^32 queries")
+	}
+	a := require.New(t)
+	key := unhex("2b7e151628aed2a6abf7158809cf4f3c")
+	oracle, err := aes.NewReducedCipherWithBackend(key, 5, false, aes.TTable)
+	a.NoError(err)
+	got, err := Attack(oracle, 5)
+	a.NoError(err)
+	a.Equal(key, got)
+}
+
+func TestAttackErrors(t *testing.T) {
+	a := require.New(t)
+	key := make([]byte, 16)
+	oracle, err := aes.NewReducedCipher(key, 4, true)
+	a.NoError(err)
+	_, err = Attack(oracle, 3)
+	a.Error(err)
+	// A final MixColumns breaks the byte-wise peeling.
+	_, err = Attack(oracle, 4)
+	a.Error(err)
+}
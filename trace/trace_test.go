@@ -0,0 +1,82 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var state = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+}
+
+func TestFunc(t *testing.T) {
+	a := require.New(t)
+	var got []string
+	var tr Tracer = Func(func(round int, step string, state []byte) {
+		got = append(got, step)
+	})
+	tr.Trace(0, "input", state)
+	tr.Trace(1, "start", state)
+	a.Equal([]string{"input", "start"}, got)
+}
+
+func TestJSONWriter(t *testing.T) {
+	a := require.New(t)
+	var buf bytes.Buffer
+	j := NewJSONWriter(&buf)
+	j.Trace(0, "input", state)
+	j.Trace(3, "m_col", state)
+	a.NoError(j.Err())
+	a.Equal(`{"round":0,"step":"input","state":"000102030405060708090a0b0c0d0e0f"}
+{"round":3,"step":"m_col","state":"000102030405060708090a0b0c0d0e0f"}
+`, buf.String())
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestJSONWriterError(t *testing.T) {
+	a := require.New(t)
+	j := NewJSONWriter(failWriter{})
+	j.Trace(0, "input", state)
+	j.Trace(0, "k_sch", state)
+	a.EqualError(j.Err(), "write failed")
+}
+
+func TestPrinter(t *testing.T) {
+	a := require.New(t)
+	var buf bytes.Buffer
+	p := NewPrinter(&buf)
+	p.Trace(0, "input", state)
+	p.Trace(0, "k_sch", state)
+	p.Trace(1, "start", state)
+	a.NoError(p.Flush())
+	a.NoError(p.Flush())
+	want := []string{
+		"round  input        k_sch",
+		"    0  00 04 08 0c  00 04 08 0c",
+		"       01 05 09 0d  01 05 09 0d",
+		"       02 06 0a 0e  02 06 0a 0e",
+		"       03 07 0b 0f  03 07 0b 0f",
+		"",
+		"round  start",
+		"    1  00 04 08 0c",
+		"       01 05 09 0d",
+		"       02 06 0a 0e",
+		"       03 07 0b 0f",
+		"",
+		"",
+	}
+	a.Equal(strings.Join(want, "\n"), buf.String())
+
+	p = NewPrinter(failWriter{})
+	p.Trace(0, "input", state)
+	a.EqualError(p.Flush(), "write failed")
+}